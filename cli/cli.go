@@ -0,0 +1,914 @@
+// Package cli runs the bottle world and renders it, on behalf of the bottle
+// command and its older aliases.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/kriskowal/bottle-world/viz"
+	"github.com/kriskowal/bottle-world/viz/colormap"
+)
+
+// Main runs the bottle command with the given arguments, the first of which
+// names the render mode, or "run" to render the outputs of a config file.
+func Main(args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" {
+		usage(os.Stderr)
+		return nil
+	}
+	c, printConfig, err := configure(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if printConfig {
+		return c.Print(os.Stdout)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return Run(ctx, c)
+}
+
+// configure returns the resolved and validated config of the arguments of
+// the bottle command, and whether to print it instead of running it.
+// A mode replaces the outputs of a config file with a single output of the
+// mode, which the output flags amend, so that the file can describe the
+// world and processes for any mode; run renders the outputs of the file.
+func configure(args []string) (c Config, printConfig bool, err error) {
+	name := args[0]
+	var mode *Mode
+	if name != "run" {
+		mode = Modes[name]
+		if mode == nil {
+			usage(os.Stderr)
+			return c, false, fmt.Errorf("unknown mode %q", name)
+		}
+	}
+
+	// Flags amend the config file that one of them names, so the flags are
+	// parsed once to find the file and again over its contents.
+	probe := Config{Outputs: make([]OutputConfig, 1)}
+	var file string
+	if err := parseFlags(name, &probe, &file, &printConfig, args[1:]); err != nil {
+		return c, false, err
+	}
+
+	c = DefaultConfig()
+	if mode != nil {
+		c.World.Settle = mode.Settle
+		c.Run.Overture = mode.Overture
+	}
+	if file != "" {
+		if err := c.Load(file); err != nil {
+			return c, false, err
+		}
+	}
+	if mode != nil {
+		c.Outputs = []OutputConfig{{Mode: mode.Name}}
+	}
+	if err := parseFlags(name, &c, &file, &printConfig, args[1:]); err != nil {
+		return c, false, err
+	}
+	c.Resolve()
+	if err := c.Validate(); err != nil {
+		return c, false, err
+	}
+	return c, printConfig, nil
+}
+
+// Alias runs the bottle command for a single mode, as the programs that
+// preceded it did, and exits if it fails.
+func Alias(mode string) {
+	if err := Main(append([]string{mode}, os.Args[1:]...)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: bottle <mode> [flags]")
+	fmt.Fprintln(w, "       bottle run -config <file> [flags]")
+	fmt.Fprintln(w, "modes:")
+	for _, name := range modeNames() {
+		fmt.Fprintf(w, "  %-12s %s\n", name, Modes[name].Description)
+	}
+	fmt.Fprintln(w, "a mode renders only its own output, in place of the outputs of a config file")
+	fmt.Fprintln(w, "run bottle <mode> -h for flags")
+}
+
+func parseFlags(name string, c *Config, file *string, printConfig *bool, args []string) error {
+	f := flag.NewFlagSet("bottle "+name, flag.ContinueOnError)
+	f.StringVar(file, "config", *file, "JSON file describing the run, which the other flags amend")
+	f.BoolVar(printConfig, "print-config", *printConfig, "print the resolved config instead of running")
+	f.Int64Var(&c.World.Seed, "seed", c.World.Seed, "offset for the seeds of the terrain")
+	f.IntVar(&c.World.Width, "width", c.World.Width, "width of the world in cells")
+	f.IntVar(&c.World.Height, "height", c.World.Height, "height of the world in cells")
+	f.BoolVar(&c.World.Settle, "settle", c.World.Settle, "settle the water before the first tick")
+	f.StringVar(&c.World.Heightmap, "heightmap", c.World.Heightmap, "grayscale png of the terrain, resampled to the size of the world")
+	f.Var((*ints)(&c.World.HeightmapRange), "heightmap-range", "lo,hi elevations of black and white in the heightmap (default -1000,1000)")
+	f.StringVar(&c.World.ExportHeightmap, "export-heightmap", c.World.ExportHeightmap, "16-bit grayscale png to write the terrain to")
+	f.StringVar(&c.Processes.Hydrology, "hydrology", c.Processes.Hydrology, "hydrology: "+strings.Join(hydrologies, ", "))
+	f.StringVar(&c.Processes.Heat, "heat", c.Processes.Heat, "heat diffusion: "+strings.Join(heats, ", "))
+	f.Var(optional(&c.Processes.LandDiffusivity), "land-diffusivity", "rate that implicit and spectral heat spread through land (default 0.2)")
+	f.Var(optional(&c.Processes.WaterDiffusivity), "water-diffusivity", "rate that implicit and spectral heat spread through water (default 0.2)")
+	f.Var(optional(&c.Processes.HeatTimeStep), "heat-time-step", "ticks of diffusion that implicit and spectral heat take each tick (default 1)")
+	f.BoolVar(&c.Processes.Groundwater, "groundwater", c.Processes.Groundwater, "soak water into soil and an aquifer")
+	f.IntVar(&c.Run.Overture, "overture", c.Run.Overture, "ticks to run before the first frame")
+	f.IntVar(&c.Run.Ticks, "ticks", c.Run.Ticks, "ticks to run after the overture (default: sample × width × duration of the mode)")
+	f.IntVar(&c.Run.Sample, "sample", c.Run.Sample, "ticks between frames (default: sample of the mode)")
+	f.Var(tracerPoints(&c.Tracers.Points, 2), "tracer", "x,y of a tracer to seed after the overture, which may repeat")
+	f.Var(tracerPoints(&c.Tracers.Lines, 5), "tracer-line", "x0,y0,x1,y1,n of n tracers to seed along a line, which may repeat")
+	f.IntVar(&c.Tracers.Random, "tracers", c.Tracers.Random, "number of tracers to seed at random")
+	f.IntVar(&c.Tracers.Every, "tracer-every", c.Tracers.Every, "ticks between the positions of the trajectories of tracers (default 1)")
+	f.StringVar(&c.Log.Path, "log", c.Log.Path, "file to log statistics of the world to after every tick, as csv or jsonl by its extension")
+	f.IntVar(&c.Log.Every, "log-every", c.Log.Every, "ticks between the records of the log (default 1)")
+	f.Var(rasters(c), "raster", "layer=path of a layer to write at the end of the run as .asc or .tif for GIS tools, which may repeat: "+strings.Join(viz.RasterLayers, ", ")+" and the other layers")
+	f.StringVar(&c.Tracers.Path, "tracer-csv", c.Tracers.Path, "file to write the trajectories of the tracers to")
+
+	// Chart flags amend the first chart, or add one.
+	chart := &ChartConfig{}
+	if len(c.Charts) > 0 {
+		chart = &c.Charts[0]
+	}
+	f.StringVar(&chart.Path, "chart", chart.Path, "png or svg file to chart statistics of the world at every frame")
+	f.Var((*names)(&chart.Stats), "chart-stats", "statistics to chart (default: the aggregates of the world)")
+
+	// Output flags amend the first output.
+	out := &OutputConfig{}
+	if len(c.Outputs) > 0 {
+		out = &c.Outputs[0]
+	}
+	outputFlags := map[string]bool{}
+	outputFlag := func(name string) string {
+		outputFlags[name] = true
+		return name
+	}
+	f.StringVar(&out.Layer, outputFlag("layer"), out.Layer, "layer to render: "+strings.Join(viz.LayerNames(), ", "))
+	f.StringVar(&out.Colormap, outputFlag("colormap"), out.Colormap, "color map of the layer: "+strings.Join(colormap.Names(), ", "))
+	f.BoolVar(&out.Legend, outputFlag("legend"), out.Legend, "add a legend of the color map of the layer")
+	f.StringVar(&out.Normalize, outputFlag("normalize"), out.Normalize, "range of the colors of each frame: "+strings.Join(normalizations, ", ")+" (default frame)")
+	f.Var(ranges(out), outputFlag("range"), "layer=lo,hi for fixed normalization, which may repeat")
+	f.Var((*floats)(&out.Percentiles), outputFlag("percentiles"), "lo,hi percentiles for percentile normalization (default 2,98)")
+	f.BoolVar(&out.Relief.Hillshade, outputFlag("hillshade"), out.Relief.Hillshade, "shade the terrain under the frames")
+	f.Var(optional(&out.Relief.Azimuth), outputFlag("azimuth"), "direction of the light in degrees clockwise from north (default 315)")
+	f.Var(optional(&out.Relief.Altitude), outputFlag("altitude"), "angle of the light above the horizon in degrees (default 45)")
+	f.Var(optional(&out.Relief.Exaggeration), outputFlag("exaggeration"), "vertical exaggeration of the hillshade (default 1)")
+	f.Var(optional(&out.Relief.Strength), outputFlag("shade"), "strength of the hillshade from 0 to 1 (default 1)")
+	f.Float64Var(&out.Relief.Contours, outputFlag("contours"), out.Relief.Contours, "interval between contour lines (default none)")
+	f.StringVar(&out.Relief.ContourLayer, outputFlag("contour-layer"), out.Relief.ContourLayer, "layer that the contour lines follow (default elevation)")
+	f.StringVar(&out.Overlay.Text, outputFlag("text"), out.Overlay.Text, "text at the top left of each frame, with fields like {tick}, {date} and {hottest-surface}")
+	f.Var((*names)(&out.Overlay.Stats), outputFlag("stats"), "statistics to list at the top left of each frame: "+strings.Join(viz.StatNames(), ", "))
+	f.BoolVar(&out.Overlay.Sun, outputFlag("sun"), out.Overlay.Sun, "mark the cell under the sun")
+	f.Var(probes(out), outputFlag("probe"), "x,y of a cell to mark, which may repeat")
+	f.StringVar(&out.Overlay.ProbeLayer, outputFlag("probe-layer"), out.Overlay.ProbeLayer, "layer whose value labels each probe")
+	f.IntVar(&out.Overlay.ScaleBar, outputFlag("scale-bar"), out.Overlay.ScaleBar, "length in cells of a scale bar (default none)")
+	f.Var((*panelNames)(&out.Panels), outputFlag("panels"), "modes or layers of the panels of the composite mode, separated by commas")
+	f.IntVar(&out.Columns, outputFlag("columns"), out.Columns, "panels in each row of the composite mode (default: enough for a square)")
+	f.IntVar(&out.Overlay.Quiver, outputFlag("quiver"), out.Overlay.Quiver, "cells between arrows along the flow of water (default none, or 8 for the flow mode)")
+	f.IntVar(&out.Overlay.Streamlines, outputFlag("streamlines"), out.Overlay.Streamlines, "cells between the seeds of lines along the flow of water (default none)")
+	f.IntVar(&out.Overlay.Trails, outputFlag("trails"), out.Overlay.Trails, "positions of the trails of tracers to draw, -tracer-every ticks apart (default none)")
+	f.Var((*names)(&out.Sparklines), outputFlag("sparklines"), "statistics to draw as lines under each frame: "+strings.Join(viz.StatNames(), ", "))
+	f.StringVar(&out.Path, outputFlag("o"), out.Path, "output file (default: name of the mode with the extension of the format)")
+	f.StringVar(&out.Format, outputFlag("format"), out.Format, "output format: "+strings.Join(formats, ", ")+" (default gif)")
+	f.IntVar(&out.Delay, outputFlag("delay"), out.Delay, "hundredths of a second between frames (default 10)")
+	f.IntVar(&out.Rate, outputFlag("rate"), out.Rate, "frames per second of y4m video (default 25)")
+	f.IntVar(&out.Scale, outputFlag("scale"), out.Scale, "pixels of y4m video across each pixel of a frame (default 1)")
+	f.StringVar(&out.Quantizer, outputFlag("quantizer"), out.Quantizer, "gif palette: "+strings.Join(quantizerNames, ", ")+" (default median-cut)")
+	f.StringVar(&out.Palette, outputFlag("palette"), out.Palette, "gif palette for each frame or the whole animation: "+strings.Join(palettes, ", ")+" (default frame)")
+	f.BoolVar(&out.Dither, outputFlag("dither"), out.Dither, "dither gif frames")
+	f.Var((*ints)(&out.View.Origin), outputFlag("origin"), "x,y of the cell at the top left of the view")
+	f.Float64Var(&out.View.Zoom, outputFlag("zoom"), out.View.Zoom, "pixels across each cell (default 1)")
+	f.StringVar(&out.View.Interpolation, outputFlag("interpolation"), out.View.Interpolation, "zoom interpolation: "+strings.Join(interpolations, ", ")+" (default nearest)")
+	f.IntVar(&out.View.Tiles, outputFlag("tiles"), out.View.Tiles, "times to repeat the torus across and down (default: a quarter again)")
+	f.Var((*ints)(&out.View.Crop), outputFlag("crop"), "x0,y0,x1,y1 of the cells to show from the origin, instead of tiles")
+
+	if err := f.Parse(args); err != nil {
+		return err
+	}
+	if f.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(f.Args(), " "))
+	}
+	if len(c.Charts) == 0 && (chart.Path != "" || chart.Stats != nil) {
+		c.Charts = append(c.Charts, *chart)
+	}
+	var err error
+	f.Visit(func(g *flag.Flag) {
+		if outputFlags[g.Name] && len(c.Outputs) == 0 && err == nil {
+			err = fmt.Errorf("-%s: the config has no output to amend", g.Name)
+		}
+	})
+	return err
+}
+
+// floats is a flag of numbers separated by commas.
+type floats []float64
+
+func (n *floats) String() string {
+	if n == nil {
+		return ""
+	}
+	s := make([]string, len(*n))
+	for i, v := range *n {
+		s[i] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return strings.Join(s, ",")
+}
+
+func (n *floats) Set(s string) error {
+	*n = nil
+	for _, f := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return err
+		}
+		*n = append(*n, v)
+	}
+	return nil
+}
+
+// optionalFloat is a flag of a number that is nil until set.
+type optionalFloat struct {
+	v **float64
+}
+
+func optional(v **float64) optionalFloat {
+	return optionalFloat{v}
+}
+
+func (f optionalFloat) String() string {
+	if f.v == nil || *f.v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(**f.v, 'g', -1, 64)
+}
+
+func (f optionalFloat) Set(s string) error {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*f.v = &v
+	return nil
+}
+
+// rangeFlag is a flag of the range of a layer, like heat=0,500, that adds to
+// the ranges of an output each time it is set.
+type rangeFlag struct {
+	o *OutputConfig
+}
+
+func ranges(o *OutputConfig) rangeFlag {
+	return rangeFlag{o}
+}
+
+func (r rangeFlag) String() string {
+	return ""
+}
+
+func (r rangeFlag) Set(s string) error {
+	// The layer of the render mode may go unnamed, until the config
+	// resolves it after every flag.
+	name, bounds := "", s
+	if i := strings.Index(s, "="); i >= 0 {
+		name, bounds = s[:i], s[i+1:]
+	}
+	var f floats
+	if err := f.Set(bounds); err != nil {
+		return err
+	}
+	if r.o.Ranges == nil {
+		r.o.Ranges = map[string][]float64{}
+	}
+	r.o.Ranges[name] = f
+	return nil
+}
+
+// rasterFlag is a flag of a layer and a path, like elevation=elevation.tif,
+// that adds to the rasters of a config each time it is set.
+type rasterFlag struct {
+	c *Config
+}
+
+func rasters(c *Config) rasterFlag {
+	return rasterFlag{c}
+}
+
+func (r rasterFlag) String() string {
+	return ""
+}
+
+func (r rasterFlag) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return fmt.Errorf("expected layer=path, not %q", s)
+	}
+	r.c.Rasters = append(r.c.Rasters, RasterConfig{Layer: s[:i], Path: s[i+1:]})
+	return nil
+}
+
+// probeFlag is a flag of the x and y of a cell, like 10,20, that adds to the
+// probes of an output each time it is set.
+type probeFlag struct {
+	o *OutputConfig
+}
+
+func probes(o *OutputConfig) probeFlag {
+	return probeFlag{o}
+}
+
+func (p probeFlag) String() string {
+	return ""
+}
+
+func (p probeFlag) Set(s string) error {
+	var cell ints
+	if err := cell.Set(s); err != nil {
+		return err
+	}
+	p.o.Overlay.Probes = append(p.o.Overlay.Probes, cell)
+	return nil
+}
+
+// panelNames is a flag of the panels of a composite, separated by commas,
+// each the name of a mode or of a layer to render.
+type panelNames []PanelConfig
+
+func (n *panelNames) String() string {
+	if n == nil {
+		return ""
+	}
+	s := make([]string, len(*n))
+	for i, p := range *n {
+		s[i] = p.Mode
+		if p.Layer != "" {
+			s[i] = p.Layer
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+func (n *panelNames) Set(s string) error {
+	*n = nil
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		p := PanelConfig{Mode: name}
+		if Modes[name] == nil && viz.Layers[name] != nil {
+			p = PanelConfig{Mode: "render", Layer: name}
+		}
+		*n = append(*n, p)
+	}
+	return nil
+}
+
+// pointFlag is a flag of a list of numbers, like the x and y of a tracer,
+// that adds to a list each time it is set.
+type pointFlag struct {
+	points *[][]float64
+	n      int
+}
+
+func tracerPoints(points *[][]float64, n int) pointFlag {
+	return pointFlag{points, n}
+}
+
+func (p pointFlag) String() string {
+	return ""
+}
+
+func (p pointFlag) Set(s string) error {
+	var f floats
+	if err := f.Set(s); err != nil {
+		return err
+	}
+	if len(f) != p.n {
+		return fmt.Errorf("expected %d numbers, got %d", p.n, len(f))
+	}
+	*p.points = append(*p.points, f)
+	return nil
+}
+
+// names is a flag of names separated by commas.
+type names []string
+
+func (n *names) String() string {
+	if n == nil {
+		return ""
+	}
+	return strings.Join(*n, ",")
+}
+
+func (n *names) Set(s string) error {
+	*n = nil
+	for _, name := range strings.Split(s, ",") {
+		*n = append(*n, strings.TrimSpace(name))
+	}
+	return nil
+}
+
+// ints is a flag of integers separated by commas.
+type ints []int
+
+func (n *ints) String() string {
+	if n == nil {
+		return ""
+	}
+	s := make([]string, len(*n))
+	for i, v := range *n {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ",")
+}
+
+func (n *ints) Set(s string) error {
+	*n = nil
+	for _, f := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return err
+		}
+		*n = append(*n, v)
+	}
+	return nil
+}
+
+// Simulation returns a simulation of a world with the processes that a
+// config selects.
+func (c *Config) Simulation(w *sim.World) *sim.Simulation {
+	s := sim.NewSimulation(w)
+	switch c.Processes.Hydrology {
+	case "greedy":
+		s.Hydrology = sim.GreedyFlow{}
+	case "shallow-water":
+		s.Hydrology = &sim.ShallowWater{}
+	case "virtual-pipes":
+		s.Hydrology = sim.NewVirtualPipes()
+	}
+	switch c.Processes.Heat {
+	case "explicit":
+		s.Heat = sim.ExplicitHeat{}
+	case "implicit":
+		s.Heat = sim.ImplicitHeat{Diffusivity: c.Processes.diffusivity(), TimeStep: *c.Processes.HeatTimeStep}
+	case "spectral":
+		s.Heat = sim.SpectralHeat{Diffusivity: c.Processes.diffusivity(), TimeStep: *c.Processes.HeatTimeStep}
+	}
+	if c.Processes.Groundwater {
+		s.Groundwater = &sim.Groundwater{}
+	}
+	if c.Tracers.seeds() {
+		s.Tracers = &sim.Tracers{Every: c.Tracers.Every}
+	}
+	return s
+}
+
+// output streams the frames of one output of a run to its file.
+type output struct {
+	OutputConfig
+	// still outputs render only the new world.
+	still      bool
+	panels     []*panel
+	grid       *viz.Grid // for composites
+	normalize  viz.Normalization
+	file       *viz.File // nil for sequences of files
+	sink       viz.Sink
+	palettizer *viz.Palettizer // for gif
+	overlays   []viz.Overlay
+	lessons    []lesson
+}
+
+// lesson is a pass over the whole run that an output learns from before it
+// writes its first frame, like the range of every frame or a palette for
+// the whole animation.
+type lesson struct {
+	observe func(s viz.Scene)
+	learned func()
+}
+
+// panel is one rendering of the world in the frames of an output.
+type panel struct {
+	title    string
+	render   func(w *sim.World) func(x, y int) color.Color
+	palette  func() color.Palette
+	layer    *viz.Layer
+	colormap *colormap.Map
+}
+
+func newOutput(oc OutputConfig) *output {
+	o := &output{
+		OutputConfig: oc,
+		overlays:     oc.Overlay.Overlays(),
+	}
+	if oc.Mode == "composite" {
+		o.grid = &viz.Grid{Columns: oc.Columns}
+		o.still = true
+		for _, pc := range oc.Panels {
+			mode := Modes[pc.Mode]
+			o.panels = append(o.panels, o.newPanel(pc.Title, mode, pc.Layer))
+			o.still = o.still && mode.Still
+		}
+	} else {
+		mode := Modes[oc.Mode]
+		o.panels = []*panel{o.newPanel("", mode, oc.Layer)}
+		o.still = mode.Still
+	}
+	switch oc.Normalize {
+	case "frame":
+		o.normalize = viz.PerFrame{}
+	case "fixed":
+		ranges := viz.Fixed{}
+		for name, r := range oc.Ranges {
+			ranges[name] = [2]float64{r[0], r[1]}
+		}
+		o.normalize = ranges
+	case "running":
+		o.normalize = &viz.Running{}
+	case "whole-run":
+		n := &viz.WholeRun{}
+		o.normalize = n
+		o.lessons = append(o.lessons, lesson{
+			// Rendering learns the ranges of the layers.
+			observe: func(s viz.Scene) {
+				for _, p := range o.panels {
+					p.render(s.World)
+				}
+			},
+			learned: n.Fix,
+		})
+	case "percentile":
+		o.normalize = viz.Percentile{Lo: oc.Percentiles[0], Hi: oc.Percentiles[1]}
+	}
+
+	if oc.Format == "gif" {
+		o.palettizer = &viz.Palettizer{
+			Quantizer: quantizers[oc.Quantizer],
+			Dither:    oc.Dither,
+		}
+		if oc.Quantizer == "fixed" {
+			o.palettizer.Palette = o.panels[0].palette()
+		} else if oc.Palette == "animation" {
+			o.lessons = append(o.lessons, lesson{
+				observe: func(s viz.Scene) { o.palettizer.Observe(o.frame(s)) },
+				learned: o.palettizer.Fix,
+			})
+		}
+	}
+	return o
+}
+
+// newPanel returns a panel that renders a world in a mode, through the
+// normalization and relief of the output.
+func (o *output) newPanel(title string, mode *Mode, layerName string) *panel {
+	p := &panel{title: title, palette: mode.Palette}
+	render := func(w *sim.World) func(*sim.Cell) color.Color {
+		return mode.Render(w, o.normalize)
+	}
+	if mode.Layered {
+		layer := viz.Layers[layerName]
+		m := layer.ColorMap
+		if o.Colormap != "" {
+			m = colormap.Maps[o.Colormap]
+		}
+		p.palette = func() color.Palette { return m.Palette(256) }
+		if layer.Categorical() {
+			p.palette = func() color.Palette {
+				palette := color.Palette{color.Black}
+				for i := range layer.Categories {
+					palette = append(palette, layer.Set.At(i))
+				}
+				return palette
+			}
+		}
+		p.layer, p.colormap = layer, m
+		render = func(w *sim.World) func(*sim.Cell) color.Color {
+			return layer.RenderWith(w, m, o.normalize)
+		}
+	}
+	p.render = func(w *sim.World) func(x, y int) color.Color {
+		getColor := render(w)
+		return func(x, y int) color.Color {
+			return getColor(&w.Field[x][y])
+		}
+	}
+	if relief := o.Relief.Relief(); relief != nil {
+		p.render = func(w *sim.World) func(x, y int) color.Color {
+			return relief.Render(w, render(w))
+		}
+	}
+	return p
+}
+
+// frame renders the world of a scene through the view of the output.
+func (o *output) frame(s viz.Scene) image.Image {
+	w := s.World
+	s.View = o.View.Viewport()
+	panels := make([]viz.Panel, len(o.panels))
+	for i, p := range o.panels {
+		img := s.View.CaptureCells(w, p.render(w))
+		viz.Annotate(img, s, o.overlays...)
+		panels[i] = viz.Panel{Title: p.title, Image: img}
+		if o.Legend && p.layer != nil && p.layer.Categorical() {
+			panels[i].Image = viz.WithCategories(img, p.layer)
+		} else if o.Legend && p.layer != nil {
+			lo, hi := o.normalize.Range(p.layer, w)
+			panels[i].Image = viz.WithLegend(img, p.colormap, lo, hi)
+		}
+	}
+	var frame image.Image = panels[0].Image
+	if o.grid != nil {
+		frame = o.grid.Compose(panels...)
+	}
+	if len(o.Sparklines) > 0 {
+		frame = viz.WithSparklines(frame, s.Series.Only(o.Sparklines...))
+	}
+	return frame
+}
+
+func (o *output) create() error {
+	if o.Format == "png" {
+		o.sink = viz.NewPNGSequence(o.Path, 0644)
+		return nil
+	}
+	f, err := viz.Create(o.Path, 0644)
+	if err != nil {
+		return err
+	}
+	o.file = f
+	switch o.Format {
+	case "gif":
+		g := viz.NewGIFWriter(f)
+		g.Palettizer = o.palettizer
+		o.sink = g
+	case "apng":
+		o.sink = viz.NewAPNGWriter(f)
+	case "y4m":
+		o.sink = viz.NewY4MWriter(f, o.Rate, o.Scale)
+	}
+	return nil
+}
+
+func (o *output) capture(s viz.Scene) error {
+	if err := o.sink.WriteFrame(o.frame(s), o.Delay); err != nil {
+		return fmt.Errorf("%s: %w", o.Path, err)
+	}
+	return nil
+}
+
+func (o *output) close() error {
+	err := o.sink.Close()
+	if err == nil && o.file != nil {
+		err = o.file.Close()
+	}
+	if err != nil {
+		o.abort()
+		return fmt.Errorf("%s: %w", o.Path, err)
+	}
+	return nil
+}
+
+func (o *output) abort() {
+	if o.file != nil {
+		o.file.Abort()
+	}
+}
+
+// Run renders the outputs of a validated config.
+// Frames are written as they are captured, and the outputs replace their
+// files only if the run succeeds.
+// Outputs that learn from the whole run, like the range of every frame or a
+// palette for the whole animation, see earlier runs of the same
+// deterministic simulation.
+// If the context is canceled, the run stops early and the outputs end with
+// the frames captured so far.
+func Run(ctx context.Context, c Config) (err error) {
+	start, err := c.World.Generate()
+	if err != nil {
+		return err
+	}
+	if c.World.ExportHeightmap != "" {
+		if err := c.World.exportHeightmap(start); err != nil {
+			return err
+		}
+	}
+	var outputs []*output
+	for _, oc := range c.Outputs {
+		outputs = append(outputs, newOutput(oc))
+	}
+	for pass := 0; ; pass++ {
+		var learning []*output
+		for _, o := range outputs {
+			if pass < len(o.lessons) {
+				learning = append(learning, o)
+			}
+		}
+		if len(learning) == 0 {
+			break
+		}
+		fmt.Fprintf(os.Stderr, "pass %d ", pass+1)
+		if _, err := play(ctx, c, start, learning, func(o *output, s viz.Scene) error {
+			o.lessons[pass].observe(s)
+			return nil
+		}, nil); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			// Nothing can be written before the outputs have learned.
+			return fmt.Errorf("stopped while learning, before the first frame: %w", err)
+		}
+		for _, o := range learning {
+			o.lessons[pass].learned()
+		}
+	}
+
+	var created []*output
+	defer func() {
+		for _, o := range created {
+			if err != nil {
+				o.abort()
+			} else {
+				err = o.close()
+			}
+		}
+	}()
+	for _, o := range outputs {
+		if err := o.create(); err != nil {
+			return err
+		}
+		created = append(created, o)
+	}
+	var step func(viz.Scene) error
+	if c.Log.Path != "" {
+		f, l, lerr := createLog(c)
+		if lerr != nil {
+			return lerr
+		}
+		defer func() {
+			if err == nil {
+				err = l.Flush()
+			}
+			if err == nil {
+				err = f.Close()
+			}
+			if err != nil {
+				f.Abort()
+			}
+		}()
+		step = func(s viz.Scene) error {
+			if s.Tick%c.Log.Every != 0 {
+				return nil
+			}
+			if err := l.Record(s.World, s.Tick); err != nil {
+				return fmt.Errorf("%s: %w", c.Log.Path, err)
+			}
+			return nil
+		}
+	}
+	last, err := play(ctx, c, start, outputs, (*output).capture, step)
+	if err != nil {
+		return err
+	}
+	if last.Tracers != nil && c.Tracers.Path != "" {
+		if err := viz.WriteFile(c.Tracers.Path, 0644, last.Tracers.WriteCSV); err != nil {
+			return err
+		}
+	}
+	for _, ch := range c.Charts {
+		if err := writeChart(ch, last.Series.Only(ch.Stats...)); err != nil {
+			return err
+		}
+	}
+	for _, r := range c.Rasters {
+		raster := viz.NewRaster(last.World, viz.Layers[r.Layer])
+		if err := viz.WriteFile(r.Path, 0644, func(out io.Writer) error {
+			return raster.Write(out, r.Path)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createLog begins the log of a run, with its config.
+func createLog(c Config) (*viz.File, *viz.Log, error) {
+	f, err := viz.Create(c.Log.Path, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+	l, err := viz.NewLog(f, c.Log.Format, c)
+	if err != nil {
+		f.Abort()
+		return nil, nil, fmt.Errorf("%s: %w", c.Log.Path, err)
+	}
+	return f, l, nil
+}
+
+// writeChart writes a chart of a series as a png or svg, by the extension of
+// its path.
+func writeChart(ch ChartConfig, series *viz.Series) error {
+	chart := viz.Chart{Title: ch.Title, Width: ch.Width, Height: ch.Height}
+	return viz.WriteFile(ch.Path, 0644, func(out io.Writer) error {
+		if filepath.Ext(ch.Path) == ".svg" {
+			return chart.WriteSVG(out, series)
+		}
+		return png.Encode(out, chart.Image(series))
+	})
+}
+
+// play runs the simulation of a config from a new world for the overture and
+// ticks of the run, and shows each output its frames: the new world for
+// stills, and every sample after the overture for animations.
+// Tracers are seeded after the overture.
+// If there is a step function, it sees every tick.
+func play(ctx context.Context, c Config, start *sim.World, outputs []*output, show func(o *output, s viz.Scene) error, step func(s viz.Scene) error) (viz.Scene, error) {
+	s := c.Simulation(start.Clone())
+	var series *viz.Series
+	if names := c.stats(); len(names) > 0 {
+		stats := make([]*viz.Stat, len(names))
+		for i, name := range names {
+			stats[i] = viz.Stats[name]
+		}
+		series = viz.NewSeries(stats...)
+	}
+	scene := func() viz.Scene {
+		return viz.Scene{World: s.Next, Tick: s.T, Tracers: s.Tracers, Series: series}
+	}
+	// The series has the statistics of every frame.
+	record := func() {
+		if series != nil {
+			series.Record(s.Next, s.T)
+		}
+	}
+
+	for _, o := range outputs {
+		if o.still {
+			record()
+			break
+		}
+	}
+	var animations []*output
+	for _, o := range outputs {
+		if o.still {
+			if err := show(o, scene()); err != nil {
+				return scene(), err
+			}
+		} else {
+			animations = append(animations, o)
+		}
+	}
+	// The run goes on even if every output is a still, for the log, charts,
+	// rasters and tracers.
+	defer fmt.Fprintln(os.Stderr)
+
+	// Overture
+	for s.T < c.Run.Overture {
+		if ctx.Err() != nil {
+			return scene(), nil
+		}
+		s.Step()
+		if step != nil {
+			if err := step(scene()); err != nil {
+				return scene(), err
+			}
+		}
+	}
+	if s.Tracers != nil {
+		c.seedTracers(s)
+	}
+
+	// Show
+	for s.T < c.Run.Overture+c.Run.Ticks {
+		if ctx.Err() != nil {
+			fmt.Fprint(os.Stderr, " stopped")
+			return scene(), nil
+		}
+		s.Step()
+		if step != nil {
+			if err := step(scene()); err != nil {
+				return scene(), err
+			}
+		}
+		if (s.T-1)%c.Run.Sample == 0 {
+			fmt.Fprint(os.Stderr, ".")
+			record()
+			for _, o := range animations {
+				if err := show(o, scene()); err != nil {
+					return scene(), err
+				}
+			}
+		}
+	}
+	return scene(), nil
+}
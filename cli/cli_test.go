@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	file := filepath.Join(t.TempDir(), "config.json")
+	assert.NoError(t, os.WriteFile(file, []byte(data), 0644))
+	return file
+}
+
+func TestFlagsAmendTheConfigFile(t *testing.T) {
+	file := writeConfig(t, `{
+		"world": {"seed": 3, "width": 64},
+		"outputs": [{"mode": "thermo", "path": "heat.gif"}, {"mode": "topo"}]
+	}`)
+	c, _, err := configure([]string{"run", "-width", "32", "-config", file, "-zoom", "2"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), c.World.Seed)
+	assert.Equal(t, 32, c.World.Width)
+	assert.Len(t, c.Outputs, 2)
+	// Output flags amend the first output.
+	assert.Equal(t, "heat.gif", c.Outputs[0].Path)
+	assert.Equal(t, 2.0, c.Outputs[0].View.Zoom)
+	assert.Equal(t, 1.0, c.Outputs[1].View.Zoom)
+}
+
+func TestModeReplacesTheOutputs(t *testing.T) {
+	file := writeConfig(t, `{
+		"world": {"seed": 3},
+		"outputs": [{"mode": "thermo", "path": "heat.gif"}, {"mode": "topo"}]
+	}`)
+	c, _, err := configure([]string{"hydro", "-config", file})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), c.World.Seed)
+	assert.Len(t, c.Outputs, 1)
+	assert.Equal(t, "hydro", c.Outputs[0].Mode)
+	assert.Equal(t, "hydro.gif", c.Outputs[0].Path)
+}
+
+func TestRangeNeedNotFollowLayer(t *testing.T) {
+	c, _, err := configure([]string{"render", "-normalize", "fixed", "-range", "0,500", "-layer", "heat"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]float64{"heat": {0, 500}}, c.Outputs[0].Ranges)
+}
+
+func TestPrintedConfigLoads(t *testing.T) {
+	c, printConfig, err := configure([]string{
+		"relief", "-print-config", "-seed", "9", "-azimuth", "0", "-heat", "implicit",
+		"-raster", "elevation=e.tif", "-chart", "chart.svg", "-text", "{date}",
+	})
+	assert.NoError(t, err)
+	assert.True(t, printConfig)
+	var out bytes.Buffer
+	assert.NoError(t, c.Print(&out))
+
+	loaded, _, err := configure([]string{"run", "-config", writeConfig(t, out.String())})
+	assert.NoError(t, err)
+	assert.Equal(t, c, loaded)
+}
+
+func TestStopWhileLearning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "heat.gif")
+	c, _, err := configure([]string{"render", "-layer", "heat", "-normalize", "whole-run", "-width", "16", "-height", "8", "-o", path})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = Run(ctx, c)
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStillsRunEveryTick(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "run.csv")
+	c, _, err := configure([]string{"topo", "-ticks", "20", "-log", log, "-width", "16", "-height", "8", "-o", filepath.Join(dir, "topo.gif")})
+	assert.NoError(t, err)
+	assert.NoError(t, Run(context.Background(), c))
+	data, err := os.ReadFile(log)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	// The metadata and header, then a record for every tick.
+	assert.Len(t, lines, 2+20)
+	assert.True(t, strings.HasPrefix(lines[len(lines)-1], "20,"))
+}
+
+func TestTracersDriftThroughStills(t *testing.T) {
+	dir := t.TempDir()
+	csv := filepath.Join(dir, "tracers.csv")
+	c, _, err := configure([]string{"topo", "-ticks", "10", "-tracer", "3,3", "-tracer-csv", csv, "-width", "16", "-height", "8", "-o", filepath.Join(dir, "topo.gif")})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c.Tracers.Every)
+	assert.NoError(t, Run(context.Background(), c))
+	data, err := os.ReadFile(csv)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	// The header, then where the tracer was seeded and after every tick.
+	assert.Len(t, lines, 1+1+10)
+}
+
+func TestCompositeOfStillAndAnimatedPanels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "composite.gif")
+	c, _, err := configure([]string{"composite", "-panels", "topo,thermo", "-width", "16", "-height", "8", "-o", path})
+	assert.NoError(t, err)
+	// The timing comes from thermo, the first animated panel.
+	assert.Equal(t, 1, c.Run.Sample)
+	assert.Equal(t, 16, c.Run.Ticks)
+	assert.NoError(t, Run(context.Background(), c))
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,1033 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/kriskowal/bottle-world/viz"
+	"github.com/kriskowal/bottle-world/viz/colormap"
+)
+
+// Config describes a run: the world to generate, the processes that run on
+// it, how long it runs, and what it renders.
+// Configs are read from JSON files, amended by flags, and validated before
+// the run.
+type Config struct {
+	World     WorldConfig    `json:"world"`
+	Processes ProcessConfig  `json:"processes"`
+	Run       RunConfig      `json:"run"`
+	Tracers   TracerConfig   `json:"tracers"`
+	Log       LogConfig      `json:"log"`
+	Outputs   []OutputConfig `json:"outputs"`
+	// Charts are charts of statistics of the world at every frame, written
+	// at the end of the run.
+	Charts []ChartConfig `json:"charts,omitempty"`
+	// Rasters are layers of the world at the end of the run, written for
+	// GIS tools.
+	Rasters []RasterConfig `json:"rasters,omitempty"`
+}
+
+// RasterConfig describes a layer of the world to write for GIS tools.
+type RasterConfig struct {
+	// Path is the file to write, an ESRI ASCII Grid if it ends with .asc
+	// or a GeoTIFF if it ends with .tif or .tiff.
+	Path  string `json:"path"`
+	Layer string `json:"layer"`
+}
+
+// ChartConfig describes a chart of statistics of the world over a run.
+type ChartConfig struct {
+	// Path is the file to write, a png or svg by its extension.
+	Path string `json:"path"`
+	// Stats are the names of the statistics to chart.
+	Stats  []string `json:"stats"`
+	Title  string   `json:"title,omitempty"`
+	Width  int      `json:"width,omitempty"`
+	Height int      `json:"height,omitempty"`
+}
+
+// defaultChartStats are the aggregates that a world keeps of each tick.
+var defaultChartStats = []string{
+	"hottest-surface",
+	"wettest",
+	"most-rapid-water",
+	"equatorial-minimum-surface-heat",
+	"equatorial-maximum-surface-heat",
+	"latminheat",
+	"latmaxheat",
+}
+
+// stats returns the names of every statistic that the charts and outputs
+// of a config follow.
+func (c *Config) stats() []string {
+	var names []string
+	seen := map[string]bool{}
+	add := func(list []string) {
+		for _, name := range list {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	for _, ch := range c.Charts {
+		add(ch.Stats)
+	}
+	for _, o := range c.Outputs {
+		add(o.Sparklines)
+	}
+	return names
+}
+
+type WorldConfig struct {
+	Seed   int64 `json:"seed"`
+	Width  int   `json:"width"`
+	Height int   `json:"height"`
+	// Settle settles the water before the first tick.
+	Settle bool `json:"settle"`
+	// Heightmap is a grayscale png of the terrain, 8 or 16 bits deep, to
+	// load instead of generating the terrain.
+	Heightmap string `json:"heightmap,omitempty"`
+	// HeightmapRange is the elevations of black and white in the
+	// heightmap, -1000 and 1000 if empty.
+	HeightmapRange []int `json:"heightmap_range,omitempty"`
+	// ExportHeightmap is a file to write the terrain to as a 16-bit
+	// grayscale png, over the heightmap range if there is a heightmap, or
+	// the range of the elevation of the terrain.
+	ExportHeightmap string `json:"export_heightmap,omitempty"`
+}
+
+// Generate returns the new world of a validated config.
+func (c WorldConfig) Generate() (*sim.World, error) {
+	var w *sim.World
+	if c.Heightmap != "" {
+		f, err := os.Open(c.Heightmap)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		img, err := png.Decode(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Heightmap, err)
+		}
+		w = sim.Heightmap{
+			Image:  img,
+			Low:    c.HeightmapRange[0],
+			High:   c.HeightmapRange[1],
+			Width:  c.Width,
+			Height: c.Height,
+		}.Generate()
+	} else {
+		w = sim.Terrain{Seed: c.Seed, Width: c.Width, Height: c.Height}.Generate()
+	}
+	if c.Settle {
+		sim.Settle(w)
+	}
+	return w, nil
+}
+
+// exportHeightmap writes the terrain of a world to the export heightmap of
+// a validated config.
+func (c WorldConfig) exportHeightmap(w *sim.World) error {
+	low, high := w.LowestSurfaceElevation, w.HighestSurfaceElevation
+	if c.Heightmap != "" {
+		low, high = c.HeightmapRange[0], c.HeightmapRange[1]
+	}
+	img := sim.HeightmapImage(w, low, high)
+	if err := viz.WriteFile(c.ExportHeightmap, 0644, func(out io.Writer) error {
+		return png.Encode(out, img)
+	}); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%s: elevations from %d to %d\n", c.ExportHeightmap, low, high)
+	return nil
+}
+
+type ProcessConfig struct {
+	Hydrology string `json:"hydrology"`
+	Heat      string `json:"heat"`
+	// LandDiffusivity and WaterDiffusivity are the rates that implicit
+	// and spectral heat spread through land and water, in square cells
+	// per tick.
+	// Like the time step, they are nil until resolved to their defaults,
+	// so that zero means zero: heat does not spread through a material of
+	// zero diffusivity.
+	LandDiffusivity  *float64 `json:"land_diffusivity,omitempty"`
+	WaterDiffusivity *float64 `json:"water_diffusivity,omitempty"`
+	// HeatTimeStep is the number of ticks of diffusion that implicit and
+	// spectral heat take each tick.
+	HeatTimeStep *float64 `json:"heat_time_step,omitempty"`
+	Groundwater  bool     `json:"groundwater"`
+}
+
+// diffusivity returns the diffusivity of a resolved config.
+func (p ProcessConfig) diffusivity() sim.Diffusivity {
+	return sim.Diffusivity{Land: *p.LandDiffusivity, Water: *p.WaterDiffusivity}
+}
+
+// diffuses reports whether the heat of a config has a diffusivity and time
+// step.
+func (p ProcessConfig) diffuses() bool {
+	return p.Heat == "implicit" || p.Heat == "spectral"
+}
+
+type RunConfig struct {
+	// Overture is the number of ticks to run before the first frame.
+	Overture int `json:"overture"`
+	// Ticks is the number of ticks to run after the overture.
+	// If zero, it is the sample times the width of the world times the
+	// duration of the first mode.
+	Ticks int `json:"ticks"`
+	// Sample is the number of ticks between frames.
+	// If zero, it is the sample of the first mode.
+	Sample int `json:"sample"`
+}
+
+// LogConfig describes a log of the statistics of the world as it runs.
+type LogConfig struct {
+	// Path is the file to write, if any.
+	Path string `json:"path,omitempty"`
+	// Format is csv or jsonl, by the extension of the path if empty.
+	Format string `json:"format,omitempty"`
+	// Every is the number of ticks between records.
+	Every int `json:"every,omitempty"`
+}
+
+// TracerConfig describes particles that drift with the water from the end
+// of the overture.
+type TracerConfig struct {
+	// Points are the x and y of each tracer, in cells.
+	Points [][]float64 `json:"points,omitempty"`
+	// Lines are the x0, y0, x1, y1 and number of tracers to seed evenly
+	// along each line.
+	Lines [][]float64 `json:"lines,omitempty"`
+	// Random is the number of tracers to seed at random.
+	Random int `json:"random,omitempty"`
+	// Every is the number of ticks between the positions of trajectories.
+	Every int `json:"every,omitempty"`
+	// Path is a CSV file to write the trajectories of the tracers to, if
+	// any.
+	Path string `json:"path,omitempty"`
+}
+
+func (t TracerConfig) seeds() bool {
+	return len(t.Points) > 0 || len(t.Lines) > 0 || t.Random > 0
+}
+
+// seedTracers seeds the tracers of a simulation, at random from the seed of
+// the world.
+func (c *Config) seedTracers(s *sim.Simulation) {
+	t := c.Tracers
+	for _, p := range t.Points {
+		s.Tracers.SeedPoint(p[0], p[1])
+	}
+	for _, l := range t.Lines {
+		s.Tracers.SeedLine(l[0], l[1], l[2], l[3], int(l[4]))
+	}
+	s.Tracers.SeedRandom(s.Next, t.Random, rand.New(rand.NewSource(c.World.Seed)))
+}
+
+type OutputConfig struct {
+	Mode string `json:"mode"`
+	// Layer is the layer that the render mode shows.
+	Layer string `json:"layer,omitempty"`
+	// Panels are the renderings that the composite mode shows in a grid.
+	Panels []PanelConfig `json:"panels,omitempty"`
+	// Columns is the number of panels in each row of a composite, or as
+	// many as make the grid square if zero.
+	Columns int `json:"columns,omitempty"`
+	// Colormap colors the layer instead of its default color map.
+	Colormap string `json:"colormap,omitempty"`
+	// Legend adds a legend of the color map of the layer under each frame.
+	Legend bool `json:"legend,omitempty"`
+	// Sparklines are the names of statistics to draw as lines over the
+	// frames so far, under each frame.
+	Sparklines []string `json:"sparklines,omitempty"`
+	// Normalize chooses the range of each layer that the colors of a frame
+	// span: the range of each frame, a fixed range, the running range of
+	// the frames so far, the range of the whole run learned in a first
+	// pass, or the range between two percentiles of each frame.
+	Normalize string `json:"normalize"`
+	// Ranges are the fixed ranges of layers by name, where the empty name
+	// stands for the layer of the render mode.
+	Ranges map[string][]float64 `json:"ranges,omitempty"`
+	// Percentiles are the low and high percentiles of percentile
+	// normalization.
+	Percentiles []float64 `json:"percentiles,omitempty"`
+	// Path is the file to write, the name of the mode with the extension
+	// of the format if empty.
+	// For png, it is a pattern that numbers a file for each frame.
+	Path string `json:"path"`
+	// Format is gif, apng for an animated png, or png for a sequence of
+	// files.
+	Format string `json:"format"`
+	// Delay is the time between frames in hundredths of a second.
+	Delay int `json:"delay"`
+	// Rate is the number of frames per second of y4m video.
+	// Frames repeat to show for their delay.
+	Rate int `json:"rate,omitempty"`
+	// Scale is the number of pixels of y4m video across each pixel of a
+	// frame.
+	Scale int `json:"scale,omitempty"`
+	// Quantizer chooses the palette of gif frames: fixed for the palette
+	// of the mode, median-cut or octree.
+	Quantizer string `json:"quantizer,omitempty"`
+	// Palette is frame for a palette for each gif frame, or animation for
+	// one palette for the whole animation, learned in a first pass.
+	Palette string `json:"palette,omitempty"`
+	// Dither diffuses the error of the palette of gif frames.
+	Dither bool       `json:"dither,omitempty"`
+	View   ViewConfig `json:"view"`
+	// Relief shades the terrain under the frames and draws contours over
+	// them.
+	Relief ReliefConfig `json:"relief"`
+	// Overlay annotates the frames.
+	Overlay OverlayConfig `json:"overlay"`
+}
+
+// OverlayConfig describes the annotations of each frame.
+type OverlayConfig struct {
+	// Text is written at the top left, with fields like {tick}, {date} and
+	// {hottest-surface} expanded for each frame.
+	Text string `json:"text,omitempty"`
+	// Stats are the names of statistics to list under the text.
+	Stats []string `json:"stats,omitempty"`
+	// Sun marks the cell under the sun.
+	Sun bool `json:"sun,omitempty"`
+	// Probes are the x and y of cells to mark.
+	Probes [][]int `json:"probes,omitempty"`
+	// ProbeLayer is the layer whose value labels each probe, if any.
+	ProbeLayer string `json:"probe_layer,omitempty"`
+	// ScaleBar is the length in cells of a scale bar at the bottom left,
+	// or zero for none.
+	ScaleBar int `json:"scale_bar,omitempty"`
+	// Quiver is the number of cells between arrows along the flow of
+	// water, or zero for none.
+	Quiver int `json:"quiver,omitempty"`
+	// Streamlines is the number of cells between the seeds of lines that
+	// follow the flow of water, or zero for none.
+	Streamlines int `json:"streamlines,omitempty"`
+	// Trails is the number of positions of the trajectories of tracers to
+	// draw, tracers.every ticks apart, or zero for none.
+	Trails int `json:"trails,omitempty"`
+}
+
+// Overlays returns the overlays of a validated config.
+func (o OverlayConfig) Overlays() []viz.Overlay {
+	var overlays []viz.Overlay
+	if o.Streamlines > 0 {
+		overlays = append(overlays, viz.Streamlines{Spacing: o.Streamlines})
+	}
+	if o.Quiver > 0 {
+		overlays = append(overlays, viz.Quiver{Spacing: o.Quiver})
+	}
+	if o.Trails > 0 {
+		overlays = append(overlays, viz.Trails{Length: o.Trails})
+	}
+	text := o.Text
+	for _, name := range o.Stats {
+		if text != "" {
+			text += "\n"
+		}
+		text += name + " {" + name + "}"
+	}
+	if text != "" {
+		overlays = append(overlays, viz.Text{Text: text})
+	}
+	if o.Sun {
+		overlays = append(overlays, viz.Sun{})
+	}
+	for i, p := range o.Probes {
+		overlays = append(overlays, viz.Probe{
+			Label: strconv.Itoa(i + 1),
+			Cell:  image.Pt(p[0], p[1]),
+			Layer: o.ProbeLayer,
+		})
+	}
+	if o.ScaleBar > 0 {
+		overlays = append(overlays, viz.ScaleBar{Cells: o.ScaleBar, Corner: viz.BottomLeft})
+	}
+	return overlays
+}
+
+// PanelConfig describes a panel of a composite.
+type PanelConfig struct {
+	// Title is written over the panel, the layer or mode if empty.
+	Title string `json:"title,omitempty"`
+	Mode  string `json:"mode"`
+	Layer string `json:"layer,omitempty"`
+}
+
+// ReliefConfig describes hillshading and contour lines.
+type ReliefConfig struct {
+	Hillshade bool `json:"hillshade,omitempty"`
+	// Azimuth is the direction of the light in degrees clockwise from
+	// north, from 0 to 360.
+	// Like the other settings of the hillshade, it is nil until resolved
+	// to its default, so that zero means zero.
+	Azimuth *float64 `json:"azimuth,omitempty"`
+	// Altitude is the angle of the light above the horizon in degrees.
+	Altitude     *float64 `json:"altitude,omitempty"`
+	Exaggeration *float64 `json:"exaggeration,omitempty"`
+	// Strength is how much the shade darkens the colors beneath it, from 0
+	// to 1.
+	Strength *float64 `json:"strength,omitempty"`
+	// Contours is the interval between contour lines, or zero for none.
+	Contours float64 `json:"contours,omitempty"`
+	// ContourLayer is the layer that the contours follow, elevation if
+	// empty.
+	ContourLayer string `json:"contour_layer,omitempty"`
+}
+
+// Relief returns the relief of a validated config, or nil if there is none.
+func (r ReliefConfig) Relief() *viz.Relief {
+	if !r.Hillshade && r.Contours == 0 {
+		return nil
+	}
+	relief := &viz.Relief{}
+	if r.Hillshade {
+		h := viz.DefaultHillshade
+		for _, setting := range []struct{ from, to *float64 }{
+			{r.Azimuth, &h.Azimuth},
+			{r.Altitude, &h.Altitude},
+			{r.Exaggeration, &h.Exaggeration},
+			{r.Strength, &h.Strength},
+		} {
+			if setting.from != nil {
+				*setting.to = *setting.from
+			}
+		}
+		relief.Hillshade = &h
+	}
+	if r.Contours != 0 {
+		relief.Contours = &viz.Contours{
+			Layer:    r.ContourLayer,
+			Interval: r.Contours,
+		}
+	}
+	return relief
+}
+
+// ViewConfig describes the part of the torus that an output shows.
+type ViewConfig struct {
+	// Origin is the x and y of the cell at the top left of the view.
+	Origin []int `json:"origin,omitempty"`
+	// Zoom is the number of pixels across each cell.
+	Zoom float64 `json:"zoom"`
+	// Interpolation is nearest to show cells as blocks when zoomed, or
+	// bilinear to blend them.
+	Interpolation string `json:"interpolation"`
+	// Tiles is the number of times to repeat the torus across and down,
+	// or a quarter again if zero.
+	Tiles int `json:"tiles,omitempty"`
+	// Crop is the x0, y0, x1 and y1 of the cells to show from the origin,
+	// instead of the tiles.
+	Crop []int `json:"crop,omitempty"`
+}
+
+// Viewport returns the viewport of a validated view.
+func (v ViewConfig) Viewport() viz.Viewport {
+	var p viz.Viewport
+	if len(v.Origin) == 2 {
+		p.Origin = image.Pt(v.Origin[0], v.Origin[1])
+	}
+	p.Zoom = v.Zoom
+	p.Bilinear = v.Interpolation == "bilinear"
+	p.Tiles = v.Tiles
+	if len(v.Crop) == 4 {
+		p.Crop = image.Rect(v.Crop[0], v.Crop[1], v.Crop[2], v.Crop[3])
+	}
+	return p
+}
+
+var hydrologies = []string{"greedy", "shallow-water", "virtual-pipes"}
+var heats = []string{"explicit", "implicit", "spectral"}
+var formats = []string{"gif", "apng", "png", "y4m"}
+
+var quantizers = map[string]viz.Quantizer{
+	"fixed":      nil,
+	"median-cut": viz.MedianCut{},
+	"octree":     viz.Octree{},
+}
+var quantizerNames = []string{"fixed", "median-cut", "octree"}
+var palettes = []string{"frame", "animation"}
+var interpolations = []string{"nearest", "bilinear"}
+var normalizations = []string{"frame", "fixed", "running", "whole-run", "percentile"}
+
+// extensions are the default endings of the paths of each format.
+// A png output is a sequence of files, one for each frame, with the number
+// of the frame in its path.
+var extensions = map[string]string{
+	"gif":  ".gif",
+	"apng": ".png",
+	"png":  "-%05d.png",
+	"y4m":  ".y4m",
+}
+
+// DefaultConfig returns the config that files and flags amend.
+func DefaultConfig() Config {
+	return Config{
+		World: WorldConfig{
+			Width:  sim.DefaultTerrain.Width,
+			Height: sim.DefaultTerrain.Height,
+		},
+		Processes: ProcessConfig{
+			Hydrology: "greedy",
+			Heat:      "explicit",
+		},
+	}
+}
+
+// Load reads a config file over a config, so that the file need only
+// mention what differs.
+func (c *Config) Load(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := c.Decode(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("%s: %w", file, explainJSON(data, err))
+	}
+	return nil
+}
+
+// Decode reads a config in JSON over a config.
+func (c *Config) Decode(r io.Reader) error {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+	if err := d.Decode(c); err != nil {
+		return err
+	}
+	if d.More() {
+		return errors.New("unexpected data after the config")
+	}
+	return nil
+}
+
+// explainJSON locates errors in a JSON document by line and column.
+func explainJSON(data []byte, err error) error {
+	var offset int64
+	var syntax *json.SyntaxError
+	var mistyped *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		offset = syntax.Offset
+	case errors.As(err, &mistyped):
+		offset = mistyped.Offset
+		err = fmt.Errorf("%s: expected %s, got %s", mistyped.Field, mistyped.Type, mistyped.Value)
+	default:
+		return err
+	}
+	line, column := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return fmt.Errorf("line %d, column %d: %w", line, column, err)
+}
+
+// Resolve fills in the parts of a config that depend on its modes.
+func (c *Config) Resolve() {
+	var first *Mode
+	if len(c.Outputs) > 0 {
+		o := c.Outputs[0]
+		first = Modes[o.Mode]
+		// A composite takes its timing from its first animated panel, if
+		// any.
+		if o.Mode == "composite" && len(o.Panels) > 0 {
+			first = Modes[o.Panels[0].Mode]
+			for _, p := range o.Panels {
+				if mode := Modes[p.Mode]; mode != nil && !mode.Still {
+					first = mode
+					break
+				}
+			}
+		}
+	}
+	if c.Run.Sample == 0 && first != nil {
+		c.Run.Sample = first.Sample
+	}
+	if c.Run.Sample == 0 {
+		c.Run.Sample = 1
+	}
+	if c.Run.Ticks == 0 && first != nil && !first.Still {
+		c.Run.Ticks = c.World.Width * c.Run.Sample * first.Duration
+	}
+	if c.World.Heightmap != "" && c.World.HeightmapRange == nil {
+		c.World.HeightmapRange = []int{-1000, 1000}
+	}
+	if p := &c.Processes; p.diffuses() {
+		for _, setting := range []struct {
+			value **float64
+			or    float64
+		}{
+			{&p.LandDiffusivity, sim.DefaultDiffusivity.Land},
+			{&p.WaterDiffusivity, sim.DefaultDiffusivity.Water},
+			{&p.HeatTimeStep, 1},
+		} {
+			if *setting.value == nil {
+				v := setting.or
+				*setting.value = &v
+			}
+		}
+	}
+	if c.Log.Path != "" {
+		if c.Log.Format == "" {
+			c.Log.Format = "csv"
+			if ext := filepath.Ext(c.Log.Path); ext == ".jsonl" || ext == ".ndjson" {
+				c.Log.Format = "jsonl"
+			}
+		}
+		if c.Log.Every == 0 {
+			c.Log.Every = 1
+		}
+	}
+	if c.Tracers.seeds() && c.Tracers.Every == 0 {
+		c.Tracers.Every = 1
+	}
+	for i := range c.Charts {
+		if c.Charts[i].Stats == nil {
+			c.Charts[i].Stats = defaultChartStats
+		}
+	}
+	for i := range c.Outputs {
+		o := &c.Outputs[i]
+		if o.Format == "" {
+			o.Format = "gif"
+		}
+		for j := range o.Panels {
+			p := &o.Panels[j]
+			if p.Title == "" {
+				p.Title = p.Mode
+				if p.Layer != "" {
+					p.Title = p.Layer
+				}
+			}
+		}
+		if o.Path == "" {
+			name := o.Mode
+			if o.Layer != "" {
+				name = o.Layer
+			}
+			o.Path = name + extensions[o.Format]
+		}
+		if o.Delay == 0 {
+			o.Delay = 10
+		}
+		if o.Format == "gif" {
+			if o.Quantizer == "" {
+				o.Quantizer = "median-cut"
+			}
+			if o.Palette == "" {
+				o.Palette = "frame"
+			}
+		}
+		if o.Normalize == "" {
+			o.Normalize = "frame"
+		}
+		if o.Mode == "flow" && o.Overlay.Quiver == 0 && o.Overlay.Streamlines == 0 {
+			o.Overlay.Quiver = 8
+		}
+		if o.Mode == "relief" && o.Relief.Contours == 0 {
+			o.Relief.Hillshade = true
+		}
+		if r := &o.Relief; r.Hillshade {
+			d := viz.DefaultHillshade
+			for _, setting := range []struct {
+				value **float64
+				or    float64
+			}{
+				{&r.Azimuth, d.Azimuth},
+				{&r.Altitude, d.Altitude},
+				{&r.Exaggeration, d.Exaggeration},
+				{&r.Strength, d.Strength},
+			} {
+				if *setting.value == nil {
+					v := setting.or
+					*setting.value = &v
+				}
+			}
+		}
+		if r, ok := o.Ranges[""]; ok && o.Layer != "" {
+			delete(o.Ranges, "")
+			o.Ranges[o.Layer] = r
+		}
+		if o.Normalize == "percentile" && o.Percentiles == nil {
+			o.Percentiles = []float64{2, 98}
+		}
+		if o.View.Zoom == 0 {
+			o.View.Zoom = 1
+		}
+		if o.View.Interpolation == "" {
+			o.View.Interpolation = "nearest"
+		}
+		if o.Format == "y4m" {
+			if o.Rate == 0 {
+				o.Rate = 25
+			}
+			if o.Scale == 0 {
+				o.Scale = 1
+			}
+		}
+	}
+}
+
+// Validate reports every problem with a config.
+func (c *Config) Validate() error {
+	var problems []string
+	problem := func(field, format string, args ...interface{}) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+	if c.World.Width <= 0 {
+		problem("world.width", "must be at least 1, not %d", c.World.Width)
+	}
+	if c.World.Height <= 0 {
+		problem("world.height", "must be at least 1, not %d", c.World.Height)
+	}
+	if r := c.World.HeightmapRange; r != nil && (len(r) != 2 || r[1] <= r[0]) {
+		problem("world.heightmap_range", "must be a low and a higher high, not %v", r)
+	}
+	if c.World.ExportHeightmap != "" && filepath.Ext(c.World.ExportHeightmap) != ".png" {
+		problem("world.export_heightmap", "%q must end with .png", c.World.ExportHeightmap)
+	}
+	if !oneOf(c.Processes.Hydrology, hydrologies) {
+		problem("processes.hydrology", "unknown hydrology %q, expected %s", c.Processes.Hydrology, strings.Join(hydrologies, ", "))
+	}
+	if !oneOf(c.Processes.Heat, heats) {
+		problem("processes.heat", "unknown heat diffusion %q, expected %s", c.Processes.Heat, strings.Join(heats, ", "))
+	}
+	if p := c.Processes; p.diffuses() {
+		if p.LandDiffusivity != nil && *p.LandDiffusivity < 0 {
+			problem("processes.land_diffusivity", "must not be negative, not %g", *p.LandDiffusivity)
+		}
+		if p.WaterDiffusivity != nil && *p.WaterDiffusivity < 0 {
+			problem("processes.water_diffusivity", "must not be negative, not %g", *p.WaterDiffusivity)
+		}
+		if p.HeatTimeStep != nil && *p.HeatTimeStep <= 0 {
+			problem("processes.heat_time_step", "must be positive, not %g", *p.HeatTimeStep)
+		}
+	} else if p.LandDiffusivity != nil || p.WaterDiffusivity != nil || p.HeatTimeStep != nil {
+		problem("processes", "only implicit and spectral heat have a diffusivity and time step, not %s", p.Heat)
+	}
+	if c.Run.Overture < 0 {
+		problem("run.overture", "must not be negative, not %d", c.Run.Overture)
+	}
+	if c.Run.Ticks < 0 {
+		problem("run.ticks", "must not be negative, not %d", c.Run.Ticks)
+	}
+	if c.Run.Sample < 1 {
+		problem("run.sample", "must be at least 1, not %d", c.Run.Sample)
+	}
+	for i, p := range c.Tracers.Points {
+		if len(p) != 2 {
+			problem(fmt.Sprintf("tracers.points[%d]", i), "must be an x and y, not %d numbers", len(p))
+		}
+	}
+	for i, l := range c.Tracers.Lines {
+		if len(l) != 5 || l[4] < 1 || l[4] != float64(int(l[4])) {
+			problem(fmt.Sprintf("tracers.lines[%d]", i), "must be an x0, y0, x1, y1 and a whole number of tracers, not %v", l)
+		}
+	}
+	if c.Tracers.Random < 0 {
+		problem("tracers.random", "must not be negative, not %d", c.Tracers.Random)
+	}
+	if c.Log.Path != "" {
+		if !oneOf(c.Log.Format, viz.LogFormats) {
+			problem("log.format", "unknown format %q, expected %s", c.Log.Format, strings.Join(viz.LogFormats, ", "))
+		}
+		if c.Log.Every < 1 {
+			problem("log.every", "must be at least 1, not %d", c.Log.Every)
+		}
+	}
+	if c.Tracers.seeds() && c.Tracers.Every < 1 {
+		problem("tracers.every", "must be at least 1, not %d", c.Tracers.Every)
+	}
+	if c.Tracers.Path != "" && !c.Tracers.seeds() {
+		problem("tracers.path", "there are no tracers to write")
+	}
+	if len(c.Outputs) == 0 {
+		problem("outputs", "must name at least one output")
+	}
+	for i, ch := range c.Charts {
+		field := fmt.Sprintf("charts[%d]", i)
+		if ext := filepath.Ext(ch.Path); ext != ".png" && ext != ".svg" {
+			problem(field+".path", "%q must end with .png or .svg", ch.Path)
+		}
+		if len(ch.Stats) == 0 {
+			problem(field+".stats", "must name at least one statistic")
+		}
+		for _, name := range ch.Stats {
+			if viz.Stats[name] == nil {
+				problem(field+".stats", "unknown statistic %q, expected %s", name, strings.Join(viz.StatNames(), ", "))
+			}
+		}
+		if ch.Width < 0 || ch.Height < 0 {
+			problem(field, "width and height must not be negative, not %d and %d", ch.Width, ch.Height)
+		}
+	}
+	for i, r := range c.Rasters {
+		field := fmt.Sprintf("rasters[%d]", i)
+		if !oneOf(filepath.Ext(r.Path), viz.RasterFormats) {
+			problem(field+".path", "%q must end with %s", r.Path, strings.Join(viz.RasterFormats, ", "))
+		}
+		if viz.Layers[r.Layer] == nil {
+			problem(field+".layer", "unknown layer %q, expected %s", r.Layer, strings.Join(viz.LayerNames(), ", "))
+		}
+	}
+	paths := map[string]int{}
+	if c.Tracers.Path != "" {
+		paths[c.Tracers.Path] = -1
+	}
+	if c.Log.Path != "" {
+		if _, ok := paths[c.Log.Path]; ok {
+			problem("log.path", "%q is the path of more than one file", c.Log.Path)
+		}
+		paths[c.Log.Path] = -1
+	}
+	if c.World.ExportHeightmap != "" {
+		if _, ok := paths[c.World.ExportHeightmap]; ok {
+			problem("world.export_heightmap", "%q is the path of more than one file", c.World.ExportHeightmap)
+		}
+		paths[c.World.ExportHeightmap] = -1
+	}
+	for _, ch := range c.Charts {
+		if _, ok := paths[ch.Path]; ok {
+			problem("charts", "%q is the path of more than one file", ch.Path)
+		}
+		paths[ch.Path] = -1
+	}
+	for _, r := range c.Rasters {
+		if _, ok := paths[r.Path]; ok {
+			problem("rasters", "%q is the path of more than one file", r.Path)
+		}
+		paths[r.Path] = -1
+	}
+	for i, o := range c.Outputs {
+		field := fmt.Sprintf("outputs[%d]", i)
+		if mode := Modes[o.Mode]; mode == nil {
+			problem(field+".mode", "unknown mode %q, expected %s", o.Mode, strings.Join(modeNames(), ", "))
+		} else if o.Mode == "composite" {
+			if len(o.Panels) == 0 {
+				problem(field+".panels", "the composite mode needs at least one panel")
+			}
+			for j, p := range o.Panels {
+				pf := fmt.Sprintf("%s.panels[%d]", field, j)
+				if mode := Modes[p.Mode]; mode == nil || p.Mode == "composite" {
+					problem(pf+".mode", "unknown mode %q, expected a mode other than composite", p.Mode)
+				} else if mode.Layered && viz.Layers[p.Layer] == nil {
+					problem(pf+".layer", "unknown layer %q, expected %s", p.Layer, strings.Join(viz.LayerNames(), ", "))
+				} else if !mode.Layered && p.Layer != "" {
+					problem(pf+".layer", "only the render mode shows a layer, not %s", p.Mode)
+				}
+			}
+			if o.Layer != "" {
+				problem(field+".layer", "the panels of a composite name their own layers")
+			}
+			if o.Quantizer == "fixed" {
+				problem(field+".quantizer", "a composite has no fixed palette")
+			}
+		} else if len(o.Panels) > 0 {
+			problem(field+".panels", "only the composite mode has panels, not %s", o.Mode)
+		} else if mode.Layered && viz.Layers[o.Layer] == nil {
+			problem(field+".layer", "unknown layer %q, expected %s", o.Layer, strings.Join(viz.LayerNames(), ", "))
+		} else if !mode.Layered && o.Layer != "" {
+			problem(field+".layer", "only the render mode shows a layer, not %s", o.Mode)
+		} else if !mode.Layered && (o.Colormap != "" || o.Legend) {
+			problem(field, "only the render mode takes a colormap or legend, not %s", o.Mode)
+		}
+		if layer := viz.Layers[o.Layer]; o.Colormap != "" && layer != nil && layer.Categorical() {
+			problem(field+".colormap", "layer %q has categories, not a colormap", o.Layer)
+		}
+		if o.Colormap != "" && colormap.Maps[o.Colormap] == nil {
+			problem(field+".colormap", "unknown colormap %q, expected %s", o.Colormap, strings.Join(colormap.Names(), ", "))
+		}
+		if o.Columns < 0 {
+			problem(field+".columns", "must not be negative, not %d", o.Columns)
+		}
+		if !oneOf(o.Format, formats) {
+			problem(field+".format", "unknown format %q, expected %s", o.Format, strings.Join(formats, ", "))
+		}
+		if o.Format == "png" && !numbered(o.Path) {
+			problem(field+".path", "%q must number the frames of a png sequence with a verb like %%05d", o.Path)
+		}
+		if o.Delay < 0 {
+			problem(field+".delay", "must not be negative, not %d", o.Delay)
+		}
+		if o.Rate < 0 {
+			problem(field+".rate", "must not be negative, not %d", o.Rate)
+		}
+		if o.Scale < 0 {
+			problem(field+".scale", "must not be negative, not %d", o.Scale)
+		}
+		if o.Format == "gif" {
+			if !oneOf(o.Quantizer, quantizerNames) {
+				problem(field+".quantizer", "unknown quantizer %q, expected %s", o.Quantizer, strings.Join(quantizerNames, ", "))
+			}
+			if !oneOf(o.Palette, palettes) {
+				problem(field+".palette", "unknown palette %q, expected %s", o.Palette, strings.Join(palettes, ", "))
+			}
+		}
+		if !oneOf(o.Normalize, normalizations) {
+			problem(field+".normalize", "unknown normalization %q, expected %s", o.Normalize, strings.Join(normalizations, ", "))
+		}
+		if o.Normalize == "fixed" && len(o.Ranges) == 0 {
+			problem(field+".ranges", "fixed normalization needs the range of at least one layer")
+		}
+		for _, name := range sortedKeys(o.Ranges) {
+			r := o.Ranges[name]
+			if name == "" {
+				problem(field+".ranges", "a range without the name of a layer needs the layer of the render mode")
+			} else if viz.Layers[name] == nil {
+				problem(field+".ranges", "unknown layer %q, expected %s", name, strings.Join(viz.LayerNames(), ", "))
+			} else if len(r) != 2 || r[1] <= r[0] {
+				problem(field+".ranges."+name, "must be a low and a higher high, not %v", r)
+			}
+		}
+		if o.Normalize == "percentile" {
+			p := o.Percentiles
+			if len(p) != 2 || p[0] < 0 || p[1] > 100 || p[1] <= p[0] {
+				problem(field+".percentiles", "must be a low and a higher high from 0 to 100, not %v", p)
+			}
+		}
+		r := o.Relief
+		if r.Azimuth != nil && (*r.Azimuth < 0 || *r.Azimuth >= 360) {
+			problem(field+".relief.azimuth", "must be from 0 to less than 360 degrees, not %g", *r.Azimuth)
+		}
+		if r.Altitude != nil && (*r.Altitude < 0 || *r.Altitude > 90) {
+			problem(field+".relief.altitude", "must be from 0 to 90 degrees, not %g", *r.Altitude)
+		}
+		if r.Exaggeration != nil && *r.Exaggeration < 0 {
+			problem(field+".relief.exaggeration", "must not be negative, not %g", *r.Exaggeration)
+		}
+		if r.Strength != nil && (*r.Strength < 0 || *r.Strength > 1) {
+			problem(field+".relief.strength", "must be from 0 to 1, not %g", *r.Strength)
+		}
+		if r.Contours < 0 {
+			problem(field+".relief.contours", "must not be negative, not %g", r.Contours)
+		}
+		if r.ContourLayer != "" && viz.Layers[r.ContourLayer] == nil {
+			problem(field+".relief.contour_layer", "unknown layer %q, expected %s", r.ContourLayer, strings.Join(viz.LayerNames(), ", "))
+		}
+		ov := o.Overlay
+		if unknown := viz.UnknownFields(ov.Text); len(unknown) > 0 {
+			problem(field+".overlay.text", "unknown fields %s, expected %s", strings.Join(unknown, ", "), strings.Join(viz.TextFields(), ", "))
+		}
+		for _, name := range ov.Stats {
+			if viz.Stats[name] == nil {
+				problem(field+".overlay.stats", "unknown statistic %q, expected %s", name, strings.Join(viz.StatNames(), ", "))
+			}
+		}
+		for j, p := range ov.Probes {
+			if len(p) != 2 {
+				problem(fmt.Sprintf("%s.overlay.probes[%d]", field, j), "must be an x and y, not %d numbers", len(p))
+			}
+		}
+		if ov.ProbeLayer != "" && viz.Layers[ov.ProbeLayer] == nil {
+			problem(field+".overlay.probe_layer", "unknown layer %q, expected %s", ov.ProbeLayer, strings.Join(viz.LayerNames(), ", "))
+		}
+		if ov.ScaleBar < 0 {
+			problem(field+".overlay.scale_bar", "must not be negative, not %d", ov.ScaleBar)
+		}
+		if ov.Quiver < 0 {
+			problem(field+".overlay.quiver", "must not be negative, not %d", ov.Quiver)
+		}
+		if ov.Streamlines < 0 {
+			problem(field+".overlay.streamlines", "must not be negative, not %d", ov.Streamlines)
+		}
+		for _, name := range o.Sparklines {
+			if viz.Stats[name] == nil {
+				problem(field+".sparklines", "unknown statistic %q, expected %s", name, strings.Join(viz.StatNames(), ", "))
+			}
+		}
+		if ov.Trails < 0 {
+			problem(field+".overlay.trails", "must not be negative, not %d", ov.Trails)
+		} else if ov.Trails > 0 && !c.Tracers.seeds() {
+			problem(field+".overlay.trails", "there are no tracers to draw")
+		}
+		if o.View.Origin != nil && len(o.View.Origin) != 2 {
+			problem(field+".view.origin", "must be an x and y, not %d numbers", len(o.View.Origin))
+		}
+		if o.View.Zoom <= 0 {
+			problem(field+".view.zoom", "must be positive, not %g", o.View.Zoom)
+		}
+		if !oneOf(o.View.Interpolation, interpolations) {
+			problem(field+".view.interpolation", "unknown interpolation %q, expected %s", o.View.Interpolation, strings.Join(interpolations, ", "))
+		}
+		if o.View.Tiles < 0 {
+			problem(field+".view.tiles", "must not be negative, not %d", o.View.Tiles)
+		}
+		if o.View.Crop != nil && (len(o.View.Crop) != 4 || o.View.Crop[2] <= o.View.Crop[0] || o.View.Crop[3] <= o.View.Crop[1]) {
+			problem(field+".view.crop", "must be an x0, y0, x1 and y1 with x0 < x1 and y0 < y1, not %v", o.View.Crop)
+		} else if o.View.Zoom > 0 && o.View.Tiles >= 0 && c.World.Width > 0 && c.World.Height > 0 {
+			w := &sim.World{Width: c.World.Width, Height: c.World.Height}
+			if bounds := o.View.Viewport().Bounds(w); bounds.Empty() {
+				problem(field+".view", "shows no pixels at zoom %g", o.View.Zoom)
+			}
+		}
+		if j, ok := paths[o.Path]; ok && j < 0 {
+			problem(field+".path", "%q is also the path of the tracers, log, heightmap or a chart", o.Path)
+		} else if ok {
+			problem(field+".path", "%q is also the path of outputs[%d]", o.Path, j)
+		}
+		paths[o.Path] = i
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Print writes a config as JSON.
+func (c *Config) Print(w io.Writer) error {
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(c)
+}
+
+func sortedKeys(m map[string][]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// numbered reports whether a path formats a frame number.
+func numbered(path string) bool {
+	return strings.Contains(path, "%") && !strings.Contains(fmt.Sprintf(path, 0), "%!")
+}
+
+func oneOf(s string, options []string) bool {
+	for _, o := range options {
+		if s == o {
+			return true
+		}
+	}
+	return false
+}
+
+func modeNames() []string {
+	names := make([]string, 0, len(Modes))
+	for name := range Modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolve(t *testing.T) {
+	c := DefaultConfig()
+	c.World.Width = 40
+	c.Processes.Heat = "spectral"
+	c.Outputs = []OutputConfig{{Mode: "hydro"}, {Mode: "relief"}}
+	c.Resolve()
+	// The first output chooses the timing of the run.
+	assert.Equal(t, 5, c.Run.Sample)
+	assert.Equal(t, 40*5, c.Run.Ticks)
+	assert.Equal(t, 0.2, *c.Processes.LandDiffusivity)
+	assert.Equal(t, 1.0, *c.Processes.HeatTimeStep)
+	o := c.Outputs[0]
+	assert.Equal(t, "hydro.gif", o.Path)
+	assert.Equal(t, "frame", o.Normalize)
+	assert.Equal(t, 1.0, o.View.Zoom)
+	r := c.Outputs[1].Relief
+	assert.True(t, r.Hillshade)
+	assert.Equal(t, 315.0, *r.Azimuth)
+	assert.NoError(t, c.Validate())
+}
+
+func TestValidate(t *testing.T) {
+	c := DefaultConfig()
+	c.Processes.Hydrology = "sloshy"
+	azimuth := 360.0
+	c.Outputs = []OutputConfig{
+		{Mode: "render", Layer: "warmth"},
+		{Mode: "topo", Path: "topo.gif", Relief: ReliefConfig{Hillshade: true, Azimuth: &azimuth}},
+		{Mode: "thermo", Path: "topo.gif"},
+	}
+	c.Resolve()
+	err := c.Validate()
+	assert.Error(t, err)
+	lines := strings.Split(err.Error(), "\n")
+	assert.Equal(t, "invalid config:", lines[0])
+	for _, want := range []string{
+		`processes.hydrology: unknown hydrology "sloshy", expected greedy, shallow-water, virtual-pipes`,
+		`outputs[0].layer: unknown layer "warmth"`,
+		`outputs[1].relief.azimuth: must be from 0 to less than 360 degrees, not 360`,
+		`outputs[2].path: "topo.gif" is also the path of outputs[1]`,
+	} {
+		assert.Contains(t, err.Error(), "\n  "+want)
+	}
+}
+
+func TestExplainJSON(t *testing.T) {
+	c := DefaultConfig()
+	data := "{\n  \"world\": {\n    \"width\": \"wide\"\n  }\n}\n"
+	err := explainJSON([]byte(data), c.Decode(strings.NewReader(data)))
+	assert.Error(t, err)
+	assert.Equal(t, "line 3, column 20: world.width: expected int, got string", err.Error())
+
+	data = "{\n  \"world\": {,}\n}"
+	err = explainJSON([]byte(data), c.Decode(strings.NewReader(data)))
+	assert.Contains(t, err.Error(), "line 2, column 14: ")
+}
+
+func TestZeroDiffusivity(t *testing.T) {
+	c, _, err := configure([]string{"thermo", "-heat", "implicit", "-land-diffusivity", "0", "-water-diffusivity", "0"})
+	assert.NoError(t, err)
+	assert.Equal(t, sim.Diffusivity{}, c.Processes.diffusivity())
+}
+
+func TestValidateEmptyView(t *testing.T) {
+	_, _, err := configure([]string{"topo", "-width", "64", "-height", "32", "-zoom", "0.01"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "outputs[0].view: shows no pixels at zoom 0.01")
+	_, _, err = configure([]string{"topo", "-width", "64", "-height", "32", "-zoom", "0.05"})
+	assert.NoError(t, err)
+}
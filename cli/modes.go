@@ -0,0 +1,267 @@
+package cli
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/husl-colors/husl-go"
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/kriskowal/bottle-world/viz"
+)
+
+// Mode is a way to render a run, with the defaults that suit it.
+type Mode struct {
+	Name        string
+	Description string
+	// Still modes render only the new world.
+	Still bool
+	// Sample is the number of ticks between frames.
+	Sample int
+	// Duration is the number of frames, in multiples of the width of the
+	// world.
+	Duration int
+	Overture int
+	Settle   bool
+	Palette  func() color.Palette
+	// Render returns a function that colors the cells of a world, with
+	// quantities scaled to the ranges that a normalization chooses.
+	Render func(w *sim.World, n viz.Normalization) func(*sim.Cell) color.Color
+	// Layered modes render the layer that each output names, instead.
+	Layered bool
+}
+
+// Modes are the render modes, by name.
+var Modes = map[string]*Mode{}
+
+func register(m *Mode) {
+	Modes[m.Name] = m
+}
+
+func init() {
+	register(&Mode{
+		Name:        "topo",
+		Description: "elevation of the terrain in gray",
+		Still:       true,
+		Palette:     viz.NewGrayScale,
+		Render: func(w *sim.World, n viz.Normalization) func(*sim.Cell) color.Color {
+			elevation := normalize(w, n, "elevation")
+			return func(c *sim.Cell) color.Color {
+				return viz.Gray(uint8(elevation(c) * 0xff))
+			}
+		},
+	})
+	register(&Mode{
+		Name:        "relief",
+		Description: "hillshaded terrain, with -contours for contour lines",
+		Still:       true,
+		Palette:     viz.NewGrayScale,
+		Render: func(w *sim.World, n viz.Normalization) func(*sim.Cell) color.Color {
+			// The relief of the output shades this.
+			return func(c *sim.Cell) color.Color {
+				return color.White
+			}
+		},
+	})
+	register(&Mode{
+		Name:        "thermo",
+		Description: "surface heat in gray",
+		Sample:      1,
+		Duration:    1,
+		Palette:     viz.NewGrayScale,
+		Render: func(w *sim.World, n viz.Normalization) func(*sim.Cell) color.Color {
+			heat := normalize(w, n, "heat")
+			return func(c *sim.Cell) color.Color {
+				return viz.Gray(uint8(heat(c) * 0xff))
+			}
+		},
+	})
+	register(&Mode{
+		Name:        "watershed",
+		Description: "direction that water flows out of each cell",
+		Sample:      100,
+		Duration:    4,
+		Palette: func() color.Palette {
+			return watershedPalette
+		},
+		Render: func(w *sim.World, n viz.Normalization) func(*sim.Cell) color.Color {
+			return func(c *sim.Cell) color.Color {
+				return watershedPalette[c.WaterShed]
+			}
+		},
+	})
+	register(&Mode{
+		Name:        "waterspeed",
+		Description: "direction and speed of the water flowing out of each cell",
+		Sample:      50,
+		Duration:    4,
+		Palette:     speedPalette,
+		Render: func(w *sim.World, n viz.Normalization) func(*sim.Cell) color.Color {
+			speed := normalize(w, n, "waterspeed")
+			return func(c *sim.Cell) color.Color {
+				return speedColor(c.WaterShed, speed(c))
+			}
+		},
+	})
+	register(&Mode{
+		Name:        "flow",
+		Description: "arrows or -streamlines along the flow of water over the terrain",
+		Sample:      50,
+		Duration:    4,
+		Palette:     viz.NewGrayScale,
+		Render: func(w *sim.World, n viz.Normalization) func(*sim.Cell) color.Color {
+			elevation := normalize(w, n, "elevation")
+			return func(c *sim.Cell) color.Color {
+				// Dim, so the arrows stand out.
+				return viz.Gray(uint8(0x20 + elevation(c)*0x80))
+			}
+		},
+	})
+	register(&Mode{
+		Name:        "flood",
+		Description: "water elevation over the terrain, from the start",
+		Sample:      180,
+		Duration:    1,
+		Palette:     waterPalette,
+		Render:      renderWaterElevation,
+	})
+	register(&Mode{
+		Name:        "hydro",
+		Description: "water elevation over the terrain, once settled",
+		Sample:      5,
+		Duration:    1,
+		Settle:      true,
+		Palette:     waterPalette,
+		Render:      renderWaterElevation,
+	})
+	register(&Mode{
+		Name:        "render",
+		Description: "any layer of the cells, chosen with -layer",
+		Sample:      1,
+		Duration:    1,
+		Layered:     true,
+	})
+	register(&Mode{
+		Name:        "composite",
+		Description: "several modes or layers side by side, chosen with -panels",
+	})
+	register(&Mode{
+		Name:        "bathymetry",
+		Description: "depth of water over the terrain",
+		Sample:      1,
+		Duration:    1,
+		Overture:    1000,
+		Palette:     waterPalette,
+		Render:      renderWaterDepth,
+	})
+}
+
+func watershedColor(n uint8) color.Color {
+	r, g, b := husl.HuslToRGB(float64(n-1)/4*360, 100.0, 50.0)
+	return color.RGBA{uint8(r * 0xff), uint8(g * 0xff), uint8(b * 0xff), 0xff}
+}
+
+var watershedPalette = color.Palette{
+	color.RGBA{0xff, 0xff, 0xff, 0xff},
+	watershedColor(0),
+	watershedColor(1),
+	watershedColor(2),
+	watershedColor(3),
+}
+
+// speedShades is the number of shades of each direction of water in the
+// waterspeed mode.
+const speedShades = 32
+
+func speedColor(direction uint8, speed float64) color.Color {
+	if direction == 0 {
+		return color.RGBA{0, 0, 0, 0xff}
+	}
+	// Speeds round to the shades of the palette.
+	speed = math.Round(math.Max(0, math.Min(1, speed))*(speedShades-1)) / (speedShades - 1)
+	r, g, b := husl.HuslToRGB((float64(direction-1))*360/4, 50, speed*100)
+	return color.RGBA{
+		uint8(r * 0xff),
+		uint8(g * 0xff),
+		uint8(b * 0xff),
+		0xff,
+	}
+}
+
+func speedPalette() color.Palette {
+	// Still water is black, like water that does not flow at all.
+	pal := color.Palette{speedColor(0, 0)}
+	for d := uint8(1); d <= 4; d++ {
+		for s := 1; s < speedShades; s++ {
+			pal = append(pal, speedColor(d, float64(s)/(speedShades-1)))
+		}
+	}
+	return pal
+}
+
+func waterColor(w, h float64) color.Color {
+	r, g, b := husl.HuslToRGB(240, 10+w*80, 10+h*80)
+	return color.RGBA{
+		uint8(r * 0xff),
+		uint8(g * 0xff),
+		uint8(b * 0xff),
+		0xff,
+	}
+}
+
+func waterPalette() color.Palette {
+	pal := make(color.Palette, 0)
+	for h := 0; h < 16; h++ {
+		for w := 0; w < 16; w++ {
+			pal = append(pal, waterColor(float64(h)/16, float64(w)/16))
+		}
+	}
+	return pal
+}
+
+// water blends the lightness of the water with the lightness of the terrain
+// in shallows, and shows the terrain alone where there is hardly any water.
+func water(c *sim.Cell, hydraulicLightness, topographicLightness float64) color.Color {
+	saturation := 0.0
+	lightness := 0.0
+	if c.Water < 10 {
+		saturation = 0
+		lightness = topographicLightness
+	} else if c.Water < 20 {
+		lightness = hydraulicLightness/2 + topographicLightness/2
+		saturation = 0.5
+	} else {
+		lightness = hydraulicLightness
+		saturation = 1
+	}
+	return waterColor(saturation, lightness)
+}
+
+func renderWaterElevation(w *sim.World, n viz.Normalization) func(*sim.Cell) color.Color {
+	hydraulic := normalize(w, n, "water-elevation")
+	topographic := normalize(w, n, "elevation")
+	return func(c *sim.Cell) color.Color {
+		return water(c, hydraulic(c), topographic(c))
+	}
+}
+
+func renderWaterDepth(w *sim.World, n viz.Normalization) func(*sim.Cell) color.Color {
+	depth := normalize(w, n, "water")
+	topographic := normalize(w, n, "elevation")
+	return func(c *sim.Cell) color.Color {
+		return water(c, 0.5-0.5*depth(c), topographic(c))
+	}
+}
+
+// normalize returns a function that scales a layer of each cell from 0 to 1
+// over the range that a normalization chooses for a world.
+func normalize(w *sim.World, n viz.Normalization, name string) func(*sim.Cell) float64 {
+	layer := viz.Layers[name]
+	lo, hi := n.Range(layer, w)
+	breadth := hi - lo
+	if breadth == 0 {
+		breadth = 1
+	}
+	return func(c *sim.Cell) float64 {
+		return math.Max(0, math.Min(1, (layer.Value(c)-lo)/breadth))
+	}
+}
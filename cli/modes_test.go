@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"fmt"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpeedPaletteHasEverySpeedColor(t *testing.T) {
+	pal := speedPalette()
+	has := map[color.Color]bool{}
+	for _, c := range pal {
+		has[c] = true
+	}
+	assert.Equal(t, len(pal), len(has))
+	for d := uint8(0); d <= 4; d++ {
+		for s := 0; s <= 100; s++ {
+			c := speedColor(d, float64(s)/100)
+			assert.True(t, has[c], fmt.Sprint(c, " for direction ", d, " and speed ", s, "%"))
+		}
+	}
+}
@@ -4,11 +4,12 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"image/gif"
+	"io"
 	"os"
 
 	"github.com/kriskowal/bottle-world/life/sim"
 	"github.com/kriskowal/bottle-world/life/viz"
+	output "github.com/kriskowal/bottle-world/viz"
 )
 
 const file = "life.gif"
@@ -48,10 +49,10 @@ func main() {
 		a, b = b, a
 	}
 
-	f, _ := os.OpenFile(file, os.O_WRONLY|os.O_CREATE, 0600)
-	defer f.Close()
-	gif.EncodeAll(f, &gif.GIF{
-		Image: imgs,
-		Delay: dels,
-	})
+	if err := output.WriteFile(file, 0644, func(w io.Writer) error {
+		return output.EncodeGIF(w, imgs, dels)
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -5,4 +5,4 @@ const seed2 = 5
 const width = 128
 const height = 128
 const dissipation = 10 / 11
-const totalWater = width * height * 100
+const initialWater = 100
@@ -0,0 +1,152 @@
+package sim
+
+import "math"
+
+// Groundwater soaks surface water into the soil, percolates it from the
+// soil down into an aquifer, and moves it slowly through the aquifer down
+// the slope of the water table.
+// Where the water table rises to the surface, springs return the water.
+type Groundwater struct {
+	// SoilCapacity is the most water the soil of a cell holds, 50 if zero.
+	SoilCapacity int
+	// Infiltration is the most surface water that soaks into the soil of a
+	// cell each tick, 2 if zero.
+	Infiltration int
+	// Percolation is the most water that drains from the soil into the
+	// aquifer each tick, once the soil is half full, 1 if zero.
+	Percolation int
+	// AquiferDepth is the thickness of the aquifer under the surface, 200
+	// if zero.
+	AquiferDepth int
+	// Porosity is the fraction of the aquifer that water fills when it is
+	// saturated, 0.3 if zero.
+	Porosity float64
+	// Permeability is the fraction of the difference in the water table
+	// between neighboring cells that flows between them each tick, 0.05 if
+	// zero.
+	Permeability float64
+
+	grid grid
+	// fraction of a unit of groundwater owed across the east and south
+	// face of each cell
+	east, south []float64
+}
+
+func (g *Groundwater) defaults() Groundwater {
+	d := Groundwater{
+		SoilCapacity: g.SoilCapacity,
+		Infiltration: g.Infiltration,
+		Percolation:  g.Percolation,
+		AquiferDepth: g.AquiferDepth,
+		Porosity:     g.Porosity,
+		Permeability: g.Permeability,
+	}
+	if d.SoilCapacity == 0 {
+		d.SoilCapacity = 50
+	}
+	if d.Infiltration == 0 {
+		d.Infiltration = 2
+	}
+	if d.Percolation == 0 {
+		d.Percolation = 1
+	}
+	if d.AquiferDepth == 0 {
+		d.AquiferDepth = 200
+	}
+	if d.Porosity == 0 {
+		d.Porosity = 0.3
+	}
+	if d.Permeability == 0 {
+		d.Permeability = 0.05
+	}
+	return d
+}
+
+// Seep moves water between the surface, soil and aquifer of a world.
+func (g *Groundwater) Seep(w *World) {
+	d := g.defaults()
+	saturated := int(d.Porosity * float64(d.AquiferDepth))
+
+	// Infiltrate and percolate.
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			c := &w.Field[x][y]
+			soak := least(d.Infiltration, c.Water, d.SoilCapacity-c.SoilMoisture)
+			if soak > 0 {
+				c.Water -= soak
+				c.SoilMoisture += soak
+			}
+			drain := least(d.Percolation, c.SoilMoisture-d.SoilCapacity/2, saturated-c.GroundWater)
+			if drain > 0 {
+				c.SoilMoisture -= drain
+				c.GroundWater += drain
+			}
+		}
+	}
+
+	// Flow down the slope of the water table.
+	// No cell gives more than a quarter of its groundwater across any face,
+	// so none runs dry.
+	table := make([][]float64, w.Width)
+	for x := range table {
+		table[x] = make([]float64, w.Height)
+		for y := range table[x] {
+			table[x][y] = d.waterTable(&w.Field[x][y])
+		}
+	}
+	// Each face carries the fraction of a unit that it owes to the next
+	// tick, so that slight slopes still flow.
+	if grid := newGrid(w); g.grid != grid || g.east == nil {
+		g.grid = grid
+		g.east = make([]float64, grid.n)
+		g.south = make([]float64, grid.n)
+	}
+	flow := func(a, b *Cell, ha, hb float64, owed *float64) {
+		f := d.Permeability*d.Porosity*(ha-hb)/2 + *owed
+		q := int(f)
+		if q > 0 {
+			q = least(q, a.GroundWater/4)
+		} else {
+			q = -least(-q, b.GroundWater/4)
+		}
+		*owed = 0
+		if q == int(f) {
+			*owed = f - float64(q)
+		}
+		a.GroundWater -= q
+		b.GroundWater += q
+	}
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			e, s := (x+1)%w.Width, (y+1)%w.Height
+			i := g.grid.at(x, y)
+			flow(&w.Field[x][y], &w.Field[e][y], table[x][y], table[e][y], &g.east[i])
+			flow(&w.Field[x][y], &w.Field[x][s], table[x][y], table[x][s], &g.south[i])
+		}
+	}
+
+	// Springs return what the aquifer cannot hold.
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			c := &w.Field[x][y]
+			if c.GroundWater > saturated {
+				c.Water += c.GroundWater - saturated
+				c.GroundWater = saturated
+			}
+			c.WaterTable = int(math.Round(d.waterTable(c)))
+		}
+	}
+}
+
+func (d Groundwater) waterTable(c *Cell) float64 {
+	return float64(c.SurfaceElevation-d.AquiferDepth) + float64(c.GroundWater)/d.Porosity
+}
+
+func least(n int, ns ...int) int {
+	for _, m := range ns {
+		if m < n {
+			n = m
+		}
+	}
+	return n
+}
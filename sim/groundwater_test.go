@@ -0,0 +1,94 @@
+package sim
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func allWaterOf(w *World) (total int) {
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			c := &w.Field[x][y]
+			total += c.Water + c.SoilMoisture + c.GroundWater
+		}
+	}
+	return
+}
+
+func TestGroundwaterConservesWater(t *testing.T) {
+	for name, h := range map[string]Hydrology{
+		"greedy":        GreedyFlow{},
+		"shallow-water": &ShallowWater{},
+		"virtual-pipes": NewVirtualPipes(),
+	} {
+		s := NewSimulation(Terrain{Seed: 3, Width: 32, Height: 24}.Generate())
+		s.Hydrology = h
+		s.Groundwater = &Groundwater{}
+		before := allWaterOf(s.Next)
+		for tick := 0; tick < 300; tick++ {
+			s.Step()
+			// Depths between whole units round into the cells, each tick
+			// leaving at most one unit in the last cell's remainder.
+			if !assert.InDelta(t, before, allWaterOf(s.Next), 1, fmt.Sprint(name, " after ", tick+1, " ticks")) {
+				break
+			}
+		}
+	}
+}
+
+func flatWorld(width, height int) *World {
+	w := &World{Width: width, Height: height, Field: NewField(width, height)}
+	return w
+}
+
+func TestGroundwaterSoaksAndDrains(t *testing.T) {
+	w := flatWorld(2, 1)
+	w.Field[0][0].Water = 5
+	w.Field[1][0].SoilMoisture = 30
+	(&Groundwater{}).Seep(w)
+	// Infiltration soaks two units into dry soil, which holds them until it
+	// is half full.
+	assert.Equal(t, 3, w.Field[0][0].Water)
+	assert.Equal(t, 2, w.Field[0][0].SoilMoisture)
+	assert.Equal(t, 0, w.Field[0][0].GroundWater)
+	// Soil over half full percolates a unit into the aquifer.
+	assert.Equal(t, 29, w.Field[1][0].SoilMoisture)
+	assert.Equal(t, 1, w.Field[1][0].GroundWater)
+}
+
+func TestGroundwaterFlowsDownTheWaterTable(t *testing.T) {
+	w := flatWorld(8, 1)
+	w.Field[0][0].GroundWater = 30
+	g := &Groundwater{}
+	g.Seep(w)
+	// The slope is slight enough that less than a unit flows at first,
+	// which the faces owe until it adds up.
+	assert.Equal(t, 30, w.Field[0][0].GroundWater)
+	for tick := 0; tick < 20; tick++ {
+		g.Seep(w)
+	}
+	assert.True(t, w.Field[0][0].GroundWater < 30)
+	assert.True(t, w.Field[1][0].GroundWater > 0)
+	assert.True(t, w.Field[7][0].GroundWater > 0)
+	assert.Equal(t, 30, allWaterOf(w))
+}
+
+func TestGroundwaterSprings(t *testing.T) {
+	w := flatWorld(3, 3)
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 3; y++ {
+			w.Field[x][y].GroundWater = 70
+		}
+	}
+	(&Groundwater{}).Seep(w)
+	// A saturated aquifer holds only 60, so the rest wells up.
+	for x := 0; x < 3; x++ {
+		for y := 0; y < 3; y++ {
+			assert.Equal(t, 60, w.Field[x][y].GroundWater)
+			assert.Equal(t, 10, w.Field[x][y].Water)
+			assert.Equal(t, 0, w.Field[x][y].WaterTable)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package sim
+
+import "math"
+
+// HeatSolver spreads the surface heat of the previous world into the next,
+// before the sun warms it and radiation cools it.
+type HeatSolver interface {
+	Diffuse(next, prev *World)
+}
+
+// wetDepth is the depth of water over which a cell counts as water rather
+// than land.
+const wetDepth = 20
+
+// Diffusivity is the rate that heat spreads through each material, in square
+// cells per tick.
+type Diffusivity struct {
+	Land  float64
+	Water float64
+}
+
+// DefaultDiffusivity matches the five-point average of ExplicitHeat.
+var DefaultDiffusivity = Diffusivity{Land: 0.2, Water: 0.2}
+
+// Of returns the diffusivity of the material of a cell.
+func (d Diffusivity) Of(c *Cell) float64 {
+	if c.Water >= wetDepth {
+		return d.Water
+	}
+	return d.Land
+}
+
+func (d Diffusivity) max() float64 {
+	return math.Max(d.Land, d.Water)
+}
+
+// ExplicitHeat averages every cell with its four neighbors once per tick.
+// Wide patterns take thousands of ticks to settle.
+type ExplicitHeat struct{}
+
+func (ExplicitHeat) Diffuse(next, prev *World) {
+	width, height := prev.Width, prev.Height
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			pc := &prev.Field[x][y]
+			pcn := &prev.Field[x][(y+height-1)%height]
+			pcs := &prev.Field[x][(y+1)%height]
+			pcw := &prev.Field[(x+width-1)%width][y]
+			pce := &prev.Field[(x+1)%width][y]
+			next.Field[x][y].SurfaceHeat = (pcn.SurfaceHeat + pcs.SurfaceHeat + pce.SurfaceHeat + pcw.SurfaceHeat + pc.SurfaceHeat) / 5
+		}
+	}
+}
+
+// ImplicitHeat diffuses heat by backward Euler, solving for the heat at the
+// end of each time step by conjugate gradients.
+// It is stable for any time step and honors the diffusivity of each
+// material.
+type ImplicitHeat struct {
+	// Diffusivity is the rate heat spreads through each material, which
+	// does not spread through a material of zero diffusivity.
+	Diffusivity Diffusivity
+	// TimeStep is the number of ticks of diffusion to take each tick, 1 if
+	// zero.
+	TimeStep float64
+	// Iterations limits the conjugate gradient solver, 100 if zero.
+	Iterations int
+}
+
+func (h ImplicitHeat) Diffuse(next, prev *World) {
+	dt := h.TimeStep
+	if dt == 0 {
+		dt = 1
+	}
+	iterations := h.Iterations
+	if iterations == 0 {
+		iterations = 100
+	}
+	diffusivity := h.Diffusivity
+
+	g := newGrid(prev)
+	// Conductance across the east and south face of each cell.
+	east := make([]float64, g.n)
+	south := make([]float64, g.n)
+	for x := 0; x < g.width; x++ {
+		for y := 0; y < g.height; y++ {
+			d := diffusivity.Of(&prev.Field[x][y])
+			east[g.at(x, y)] = dt * (d + diffusivity.Of(&prev.Field[(x+1)%g.width][y])) / 2
+			south[g.at(x, y)] = dt * (d + diffusivity.Of(&prev.Field[x][(y+1)%g.height])) / 2
+		}
+	}
+	apply := func(out, v []float64) {
+		for x := 0; x < g.width; x++ {
+			for y := 0; y < g.height; y++ {
+				i := g.at(x, y)
+				e, s := g.at((x+1)%g.width, y), g.at(x, (y+1)%g.height)
+				w, n := g.at((x+g.width-1)%g.width, y), g.at(x, (y+g.height-1)%g.height)
+				out[i] = v[i] +
+					east[i]*(v[i]-v[e]) + east[w]*(v[i]-v[w]) +
+					south[i]*(v[i]-v[s]) + south[n]*(v[i]-v[n])
+			}
+		}
+	}
+
+	b := g.heat(prev)
+	u := append([]float64(nil), b...)
+	r := make([]float64, g.n)
+	p := make([]float64, g.n)
+	ap := make([]float64, g.n)
+	apply(ap, u)
+	rr := 0.0
+	for i := range r {
+		r[i] = b[i] - ap[i]
+		p[i] = r[i]
+		rr += r[i] * r[i]
+	}
+	for k := 0; k < iterations && rr > 1e-6; k++ {
+		apply(ap, p)
+		pap := 0.0
+		for i := range p {
+			pap += p[i] * ap[i]
+		}
+		alpha := rr / pap
+		next := 0.0
+		for i := range u {
+			u[i] += alpha * p[i]
+			r[i] -= alpha * ap[i]
+			next += r[i] * r[i]
+		}
+		beta := next / rr
+		rr = next
+		for i := range p {
+			p[i] = r[i] + beta*p[i]
+		}
+	}
+	g.setHeat(next, u)
+}
+
+// SpectralHeat diffuses heat exactly in the frequency domain, which suits
+// the periodic torus and is stable for any time step.
+// The spectrum decays at the highest diffusivity of any material, and each
+// cell then takes the share of that change that its own material allows,
+// with whatever heat the shares gain or lose spread back over the cells in
+// proportion to their shares.
+// Where materials differ, this only approximates diffusion with a
+// diffusivity that varies from cell to cell, which ImplicitHeat solves.
+type SpectralHeat struct {
+	// Diffusivity is the rate heat spreads through each material, which
+	// does not spread through a material of zero diffusivity.
+	Diffusivity Diffusivity
+	// TimeStep is the number of ticks of diffusion to take each tick, 1 if
+	// zero.
+	TimeStep float64
+}
+
+func (h SpectralHeat) Diffuse(next, prev *World) {
+	dt := h.TimeStep
+	if dt == 0 {
+		dt = 1
+	}
+	diffusivity := h.Diffusivity
+	g := newGrid(prev)
+	before := g.heat(prev)
+	d := diffusivity.max()
+	if d <= 0 {
+		g.setHeat(next, before)
+		return
+	}
+
+	spectrum := make([]complex128, g.n)
+	for i, v := range before {
+		spectrum[i] = complex(v, 0)
+	}
+	g.fft(spectrum, false)
+	for kx := 0; kx < g.width; kx++ {
+		sx := math.Sin(math.Pi * float64(kx) / float64(g.width))
+		for ky := 0; ky < g.height; ky++ {
+			sy := math.Sin(math.Pi * float64(ky) / float64(g.height))
+			// Eigenvalue of the five-point Laplacian for this frequency.
+			lambda := 4*sx*sx + 4*sy*sy
+			spectrum[g.at(kx, ky)] *= complex(math.Exp(-d*dt*lambda), 0)
+		}
+	}
+	g.fft(spectrum, true)
+
+	after := make([]float64, g.n)
+	shares := make([]float64, g.n)
+	gained, total := 0.0, 0.0
+	for x := 0; x < g.width; x++ {
+		for y := 0; y < g.height; y++ {
+			i := g.at(x, y)
+			share := diffusivity.Of(&prev.Field[x][y]) / d
+			change := share * (real(spectrum[i]) - before[i])
+			after[i] = before[i] + change
+			shares[i] = share
+			gained += change
+			total += share
+		}
+	}
+	if total > 0 {
+		for i := range after {
+			after[i] -= gained * shares[i] / total
+		}
+	}
+	g.setHeat(next, after)
+}
+
+// grid lays out a field of floating point values over the cells of a world.
+type grid struct {
+	width, height, n int
+}
+
+func newGrid(w *World) grid {
+	return grid{width: w.Width, height: w.Height, n: w.Width * w.Height}
+}
+
+func (g grid) at(x, y int) int {
+	return x*g.height + y
+}
+
+func (g grid) heat(w *World) []float64 {
+	v := make([]float64, g.n)
+	for x := 0; x < g.width; x++ {
+		for y := 0; y < g.height; y++ {
+			v[g.at(x, y)] = float64(w.Field[x][y].SurfaceHeat)
+		}
+	}
+	return v
+}
+
+// setHeat rounds heat into the cells of a world, carrying the remainder
+// from each cell to the next so that no heat is lost.
+func (g grid) setHeat(w *World, v []float64) {
+	carry := 0.0
+	for x := 0; x < g.width; x++ {
+		for y := 0; y < g.height; y++ {
+			heat := v[g.at(x, y)] + carry
+			w.Field[x][y].SurfaceHeat = int(math.Round(heat))
+			carry = heat - float64(w.Field[x][y].SurfaceHeat)
+		}
+	}
+}
+
+// fft transforms a field in both directions.
+func (g grid) fft(v []complex128, inverse bool) {
+	column := make([]complex128, g.height)
+	for x := 0; x < g.width; x++ {
+		copy(column, v[g.at(x, 0):g.at(x, 0)+g.height])
+		fft(column, inverse)
+		copy(v[g.at(x, 0):], column)
+	}
+	row := make([]complex128, g.width)
+	for y := 0; y < g.height; y++ {
+		for x := range row {
+			row[x] = v[g.at(x, y)]
+		}
+		fft(row, inverse)
+		for x := range row {
+			v[g.at(x, y)] = row[x]
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package sim
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// diffuse runs a heat solver over a world for some ticks.
+func diffuse(h HeatSolver, w *World, ticks int) *World {
+	prev, next := w.Clone(), w.Clone()
+	for tick := 0; tick < ticks; tick++ {
+		h.Diffuse(next, prev)
+		next, prev = prev, next
+	}
+	return prev
+}
+
+func totalHeatOf(w *World) (total int) {
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			total += w.Field[x][y].SurfaceHeat
+		}
+	}
+	return
+}
+
+func TestHeatSolversAgree(t *testing.T) {
+	w := &World{Width: 16, Height: 8, Field: NewField(16, 8)}
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			w.Field[x][y].SurfaceHeat = int(1000 + 500*math.Cos(2*math.Pi*float64(x)/16))
+		}
+	}
+	explicit := diffuse(ExplicitHeat{}, w, 10)
+	// The default diffusivity matches the explicit average.
+	for _, h := range []HeatSolver{ImplicitHeat{Diffusivity: DefaultDiffusivity}, SpectralHeat{Diffusivity: DefaultDiffusivity}} {
+		got := diffuse(h, w, 10)
+		for x := 0; x < w.Width; x++ {
+			assert.InDelta(t, explicit.Field[x][3].SurfaceHeat, got.Field[x][3].SurfaceHeat, 8, fmt.Sprintf("%T at %d", h, x))
+		}
+	}
+}
+
+func TestHeatSolversConserveHeat(t *testing.T) {
+	w := &World{Width: 16, Height: 12, Field: NewField(16, 12)}
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			c := &w.Field[x][y]
+			c.SurfaceHeat = (x*37 + y*91) % 500
+			if x < 6 {
+				c.Water = wetDepth
+			}
+		}
+	}
+	before := totalHeatOf(w)
+	d := Diffusivity{Land: 0.1, Water: 0.6}
+	for _, h := range []HeatSolver{
+		ImplicitHeat{Diffusivity: d},
+		ImplicitHeat{Diffusivity: d, TimeStep: 5},
+		SpectralHeat{Diffusivity: d},
+		SpectralHeat{Diffusivity: d, TimeStep: 5},
+	} {
+		assert.InDelta(t, before, totalHeatOf(diffuse(h, w, 20)), 1, fmt.Sprintf("%+v", h))
+	}
+}
+
+func TestZeroDiffusivityKeepsHeat(t *testing.T) {
+	w := &World{Width: 8, Height: 4, Field: NewField(8, 4)}
+	w.Field[3][2].SurfaceHeat = 1000
+	for _, h := range []HeatSolver{ImplicitHeat{}, SpectralHeat{}} {
+		got := diffuse(h, w, 5)
+		assert.Equal(t, 1000, got.Field[3][2].SurfaceHeat, fmt.Sprintf("%T", h))
+		assert.Equal(t, 1000, totalHeatOf(got), fmt.Sprintf("%T", h))
+	}
+}
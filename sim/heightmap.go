@@ -0,0 +1,96 @@
+package sim
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Heightmap describes a world with terrain from the lightness of an image,
+// resampled to the size of the world.
+type Heightmap struct {
+	Image image.Image
+	// Low and High are the elevations of black and white.
+	Low, High int
+	// Width and Height are the size of the world, the size of the image if
+	// zero.
+	Width, Height int
+}
+
+// Generate returns a new world with this terrain.
+func (h Heightmap) Generate() *World {
+	world := &World{}
+	h.Reset(world)
+	return world
+}
+
+// Reset replaces a world with this terrain, covered evenly in water.
+// Each cell takes the elevation of the center of its part of the image,
+// blended between the nearest pixels.
+func (h Heightmap) Reset(w *World) {
+	b := h.Image.Bounds()
+	width, height := h.Width, h.Height
+	if width == 0 {
+		width = b.Dx()
+	}
+	if height == 0 {
+		height = b.Dy()
+	}
+	lightness := func(x, y int) float64 {
+		x = clampInt(x, 0, b.Dx()-1)
+		y = clampInt(y, 0, b.Dy()-1)
+		return float64(color.Gray16Model.Convert(h.Image.At(b.Min.X+x, b.Min.Y+y)).(color.Gray16).Y) / 0xffff
+	}
+	w.resetTerrain(width, height, func(x, y int) int {
+		// The center of the cell in pixels, from the center of the first.
+		u := (float64(x)+0.5)*float64(b.Dx())/float64(width) - 0.5
+		v := (float64(y)+0.5)*float64(b.Dy())/float64(height) - 0.5
+		x0, y0 := int(math.Floor(u)), int(math.Floor(v))
+		tx, ty := u-float64(x0), v-float64(y0)
+		l := (lightness(x0, y0)*(1-tx)+lightness(x0+1, y0)*tx)*(1-ty) +
+			(lightness(x0, y0+1)*(1-tx)+lightness(x0+1, y0+1)*tx)*ty
+		return int(math.Round(float64(h.Low) + l*float64(h.High-h.Low)))
+	})
+	// The extent of generated terrain reaches sea level, but a heightmap
+	// may lie wholly above or below it.
+	w.LowestSurfaceElevation = w.Field[0][0].SurfaceElevation
+	w.HighestSurfaceElevation = w.Field[0][0].SurfaceElevation
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			el := w.Field[x][y].SurfaceElevation
+			if el > w.HighestSurfaceElevation {
+				w.HighestSurfaceElevation = el
+			}
+			if el < w.LowestSurfaceElevation {
+				w.LowestSurfaceElevation = el
+			}
+		}
+	}
+}
+
+// HeightmapImage returns the terrain of a world as a 16-bit grayscale
+// image, with elevations from low to high scaled from black to white.
+func HeightmapImage(w *World, low, high int) *image.Gray16 {
+	img := image.NewGray16(image.Rect(0, 0, w.Width, w.Height))
+	breadth := float64(high - low)
+	if breadth == 0 {
+		breadth = 1
+	}
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			l := float64(w.Field[x][y].SurfaceElevation-low) / breadth
+			img.SetGray16(x, y, color.Gray16{uint16(math.Round(clamp(l, 0, 1) * 0xffff))})
+		}
+	}
+	return img
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
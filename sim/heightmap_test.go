@@ -0,0 +1,46 @@
+package sim
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeightmapRoundTrip(t *testing.T) {
+	w := Terrain{Seed: 7, Width: 40, Height: 30}.Generate()
+	low, high := w.LowestSurfaceElevation, w.HighestSurfaceElevation
+
+	var out bytes.Buffer
+	assert.NoError(t, png.Encode(&out, HeightmapImage(w, low, high)))
+	img, err := png.Decode(&out)
+	assert.NoError(t, err)
+	assert.Equal(t, color.Gray16Model, img.ColorModel())
+
+	v := Heightmap{Image: img, Low: low, High: high}.Generate()
+	assert.Equal(t, w.Width, v.Width)
+	assert.Equal(t, w.Height, v.Height)
+	assert.Equal(t, w.Field, v.Field)
+	// The extent of generated terrain reaches sea level, but this terrain
+	// lies wholly below it.
+	assert.Equal(t, 0, high)
+	assert.Equal(t, low, v.LowestSurfaceElevation)
+	assert.True(t, v.HighestSurfaceElevation < 0)
+}
+
+func TestHeightmapResamples(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 2, 1))
+	img.SetGray(1, 0, color.Gray{0xff})
+	w := Heightmap{Image: img, Low: 100, High: 500, Width: 4, Height: 2}.Generate()
+	var row []int
+	for x := 0; x < 4; x++ {
+		row = append(row, w.Field[x][1].SurfaceElevation)
+	}
+	// The pixels are at the centers of the halves of the world.
+	assert.Equal(t, []int{100, 200, 400, 500}, row)
+	assert.Equal(t, 100, w.LowestSurfaceElevation)
+	assert.Equal(t, 500, w.HighestSurfaceElevation)
+}
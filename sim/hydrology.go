@@ -0,0 +1,176 @@
+package sim
+
+import "math"
+
+// Hydrology moves surface water from the previous world into the next.
+// Every cell of the next world starts with the water of the previous, and
+// the hydrology sets its Water, WaterShed, WaterSpeed, WaterDX and WaterDY,
+// along with the MostRapidWater of the world.
+type Hydrology interface {
+	Flow(next, prev *World)
+}
+
+// GreedyFlow moves water from each cell halfway toward equilibrium with its
+// lowest neighbor, dampened to a third.
+// It has no momentum.
+type GreedyFlow struct{}
+
+func (GreedyFlow) Flow(next, prev *World) {
+	width, height := prev.Width, prev.Height
+	next.MostRapidWater = 0
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			// Compute water gradient
+			// {prev,next}cell{north,south,east,west}
+			pc := &prev.Field[x][y]
+			pcn := &prev.Field[x][(y+height-1)%height]
+			pcs := &prev.Field[x][(y+1)%height]
+			pcw := &prev.Field[(x+width-1)%width][y]
+			pce := &prev.Field[(x+1)%width][y]
+
+			nc := &next.Field[x][y]
+			ncn := &next.Field[x][(y+height-1)%height]
+			ncs := &next.Field[x][(y+1)%height]
+			ncw := &next.Field[(x+width-1)%width][y]
+			nce := &next.Field[(x+1)%width][y]
+
+			pt := pc
+			nt := nc
+			dx, dy := 0, 0
+			nc.WaterShed = pc.WaterShed
+			if pcn.WaterElevation < pt.WaterElevation {
+				pt = pcn
+				nt = ncn
+				nc.WaterShed = 1
+				dx, dy = 0, -1
+			}
+			if pcs.WaterElevation < pt.WaterElevation {
+				pt = pcs
+				nt = ncs
+				nc.WaterShed = 2
+				dx, dy = 0, 1
+			}
+			if pcw.WaterElevation < pt.WaterElevation {
+				pt = pcw
+				nt = ncw
+				nc.WaterShed = 3
+				dx, dy = -1, 0
+			}
+			if pce.WaterElevation < pt.WaterElevation {
+				pt = pce
+				nt = nce
+				nc.WaterShed = 4
+				dx, dy = 1, 0
+			}
+
+			equilibrium := pc.WaterElevation/2 + pt.WaterElevation/2
+			delta := pc.WaterElevation - equilibrium
+			if delta > pc.Water {
+				delta = pc.Water
+			}
+			// dampen water flow
+			if delta > 3 {
+				delta = delta / 3
+			}
+			nt.Water += delta
+			nc.Water -= delta
+			nc.WaterDX = dx * delta
+			nc.WaterDY = dy * delta
+
+			nc.WaterSpeed = delta
+			if nc.WaterSpeed > next.MostRapidWater {
+				next.MostRapidWater = nc.WaterSpeed
+			}
+
+		}
+	}
+}
+
+// surfaceWater carries the depth of water between ticks with more precision
+// than cells hold, taking up any change that other processes have made to
+// the cells in the meantime.
+type surfaceWater struct {
+	grid
+	depth   []float64
+	written []int
+	// debt is water that other processes took from cells beyond their
+	// depth, which the next cells with water repay.
+	debt float64
+}
+
+func (s *surfaceWater) load(prev *World) {
+	g := newGrid(prev)
+	if s.grid != g || s.depth == nil {
+		s.grid = g
+		s.depth = make([]float64, g.n)
+		s.written = make([]int, g.n)
+		s.debt = 0
+	}
+	// Rounding may write a cell a unit more than its depth, which another
+	// process may then take, so the depth changes by exactly as much as the
+	// cell did, and any depth below zero becomes a debt.
+	for x := 0; x < g.width; x++ {
+		for y := 0; y < g.height; y++ {
+			i := g.at(x, y)
+			s.depth[i] += float64(prev.Field[x][y].Water - s.written[i])
+			if s.depth[i] < 0 {
+				s.debt -= s.depth[i]
+				s.depth[i] = 0
+			} else if s.debt > 0 {
+				repay := math.Min(s.debt, s.depth[i])
+				s.depth[i] -= repay
+				s.debt -= repay
+			}
+		}
+	}
+}
+
+// store rounds the depth of water into the cells, carrying the remainder
+// from each cell to the next so that no water is lost.
+func (s *surfaceWater) store(next *World) {
+	carry := 0.0
+	for x := 0; x < s.width; x++ {
+		for y := 0; y < s.height; y++ {
+			i := s.at(x, y)
+			depth := s.depth[i] + carry
+			water := int(math.Max(0, math.Round(depth)))
+			carry = depth - float64(water)
+			s.written[i] = water
+			next.Field[x][y].Water = water
+		}
+	}
+}
+
+// reportFlow describes the water moving out of each cell, given the volume
+// of water that crossed the east and south face of each cell during the
+// tick.
+func reportFlow(next, prev *World, g grid, east, south []float64) {
+	next.MostRapidWater = 0
+	for x := 0; x < g.width; x++ {
+		for y := 0; y < g.height; y++ {
+			i := g.at(x, y)
+			n := g.at(x, (y+g.height-1)%g.height)
+			w := g.at((x+g.width-1)%g.width, y)
+			nc := &next.Field[x][y]
+
+			outflows := [4]float64{-south[n], south[i], -east[w], east[i]}
+			nc.WaterShed = prev.Field[x][y].WaterShed
+			speed, most := 0.0, 0.0
+			for d, out := range outflows {
+				if out > 0 {
+					speed += out
+				}
+				if out > most {
+					most = out
+					nc.WaterShed = uint8(d + 1)
+				}
+			}
+			nc.WaterSpeed = int(math.Round(speed))
+			nc.WaterDX = int(math.Round((east[w] + east[i]) / 2))
+			nc.WaterDY = int(math.Round((south[n] + south[i]) / 2))
+			if nc.WaterSpeed > next.MostRapidWater {
+				next.MostRapidWater = nc.WaterSpeed
+			}
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package sim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVirtualPipesSettleFlat(t *testing.T) {
+	w := flows(t, NewVirtualPipes(), walledBasin(), 2000)
+	assertLevel(t, w)
+	assert.Equal(t, 0, w.Field[0][5].Water)
+}
+
+func TestVirtualPipesWithoutMomentum(t *testing.T) {
+	// With zero damping the pipes keep no outflow, so water only flows
+	// while it is out of level.
+	w := flows(t, &VirtualPipes{Conductance: 0.05}, walledBasin(), 2000)
+	assertLevel(t, w)
+}
@@ -0,0 +1,302 @@
+package sim
+
+import "sort"
+
+// Settle replaces the water in a world with the steady state that Tick would
+// eventually reach, so runs can begin without a long overture.
+// Water runs down into basins, basins fill to their spill points, and any
+// excess runs on into the neighboring basin.
+// The total volume of water is preserved.
+func Settle(w *World) {
+	b := newBasins(w)
+	for n, water := range b.drain() {
+		if water > 0 {
+			b.pour(b.root, water, n)
+		}
+	}
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			w.Field[x][y].Water = 0
+		}
+	}
+	b.settle(b.root)
+	bathymetry(w)
+}
+
+// basin is a node in the tree of basins that join as water rises.
+// Leaves are the lowest points of the terrain.
+// Every other basin joins two lower basins at the elevation of the saddle
+// between them.
+type basin struct {
+	children [2]int // lower basins, or -1 for a leaf
+	inlets   [2]int // basins that water spilling over the saddle runs into
+	saddle   int    // elevation where the children join
+	cells    []int  // cells that join this basin directly, lowest first
+	count    int    // cells in this basin and all below
+	floor    int    // total surface elevation of those cells
+	volume   int    // water held in this basin and all below
+	parent   int
+	// post-order position of the basin and of its first descendant
+	post, first int
+}
+
+// capacity returns the volume of water needed to fill a basin to an
+// elevation at or above all of its cells.
+func (b *basin) capacity(level int) int {
+	return b.count*level - b.floor
+}
+
+type basins struct {
+	w      *World
+	nodes  []basin
+	joined []int // the basin each cell joined, or -1 if not yet reached
+	root   int
+}
+
+// newBasins builds the tree of basins by raising the water level over the
+// cells from the lowest to the highest.
+func newBasins(w *World) *basins {
+	n := w.Width * w.Height
+	b := &basins{
+		w:      w,
+		joined: make([]int, n),
+	}
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+		b.joined[i] = -1
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return b.elevation(order[i]) < b.elevation(order[j])
+	})
+
+	for _, i := range order {
+		// Find the distinct basins that this cell touches.
+		var around, entries [4]int
+		k := 0
+		for _, n := range b.neighbors(i) {
+			if b.joined[n] < 0 {
+				continue
+			}
+			r := b.top(b.joined[n])
+			dup := false
+			for _, o := range around[:k] {
+				dup = dup || o == r
+			}
+			if !dup {
+				around[k] = r
+				entries[k] = n
+				k++
+			}
+		}
+
+		var into int
+		if k == 0 {
+			into = b.add(basin{children: [2]int{-1, -1}})
+		} else {
+			into = around[0]
+			for m := 1; m < k; m++ {
+				into = b.add(basin{
+					children: [2]int{into, around[m]},
+					inlets:   [2]int{b.drainOf(entries[0]), b.drainOf(entries[m])},
+					saddle:   b.elevation(i),
+				})
+			}
+		}
+		node := &b.nodes[into]
+		node.cells = append(node.cells, i)
+		node.count++
+		node.floor += b.elevation(i)
+		b.joined[i] = into
+	}
+	b.root = b.top(b.joined[order[0]])
+	b.number(b.root, 0)
+	return b
+}
+
+func (b *basins) add(node basin) int {
+	n := len(b.nodes)
+	node.parent = -1
+	for _, c := range node.children {
+		if c >= 0 {
+			child := &b.nodes[c]
+			child.parent = n
+			node.count += child.count
+			node.floor += child.floor
+		}
+	}
+	b.nodes = append(b.nodes, node)
+	return n
+}
+
+// number visits the basins in post-order so that every basin within another
+// falls between its first descendant and itself.
+func (b *basins) number(n, next int) int {
+	node := &b.nodes[n]
+	node.first = next
+	for _, c := range node.children {
+		if c >= 0 {
+			next = b.number(c, next)
+		}
+	}
+	node.post = next
+	return next + 1
+}
+
+// top returns the highest basin that contains another.
+func (b *basins) top(n int) int {
+	for b.nodes[n].parent >= 0 {
+		n = b.nodes[n].parent
+	}
+	return n
+}
+
+// contains reports whether one basin lies within another.
+func (b *basins) contains(n, m int) bool {
+	return b.nodes[n].first <= b.nodes[m].post && b.nodes[m].post <= b.nodes[n].post
+}
+
+// full returns the volume of water that fills a basin to its saddle.
+// Beyond that, the water stands in a single lake over all its cells.
+func (b *basins) full(n int) int {
+	node := &b.nodes[n]
+	return b.nodes[node.children[0]].capacity(node.saddle) + b.nodes[node.children[1]].capacity(node.saddle)
+}
+
+func (b *basins) cell(i int) *Cell {
+	return &b.w.Field[i/b.w.Height][i%b.w.Height]
+}
+
+func (b *basins) elevation(i int) int {
+	return b.cell(i).SurfaceElevation
+}
+
+func (b *basins) neighbors(i int) [4]int {
+	x, y := i/b.w.Height, i%b.w.Height
+	width, height := b.w.Width, b.w.Height
+	return [4]int{
+		x*height + (y+height-1)%height,
+		x*height + (y+1)%height,
+		((x+width-1)%width)*height + y,
+		((x+1)%width)*height + y,
+	}
+}
+
+// drainOf follows the steepest descent from a cell to the bottom and returns
+// the basin that the water comes to rest in.
+func (b *basins) drainOf(i int) int {
+	for {
+		d := i
+		for _, n := range b.neighbors(i) {
+			if b.elevation(n) < b.elevation(d) {
+				d = n
+			}
+		}
+		if d == i {
+			return b.joined[i]
+		}
+		i = d
+	}
+}
+
+// drain collects the water of every cell by the basin it runs down into.
+func (b *basins) drain() []int {
+	water := make([]int, len(b.nodes))
+	for i := range b.joined {
+		water[b.drainOf(i)] += b.cell(i).Water
+	}
+	return water
+}
+
+// pour adds water to a basin where it runs into an inner basin.
+// Water fills the inner basin up to the saddle, then spills over into its
+// sibling, and once both are full it stands over the saddle.
+func (b *basins) pour(n, amount, inlet int) {
+	node := &b.nodes[n]
+	if node.children[0] < 0 {
+		node.volume += amount
+		return
+	}
+	before := node.volume
+	node.volume += amount
+	if before >= b.full(n) {
+		return
+	}
+
+	side := 0
+	if inlet == n {
+		inlet = node.inlets[0]
+	} else if b.contains(node.children[1], inlet) {
+		side = 1
+	}
+	for k := 0; k < 2 && amount > 0; k++ {
+		c := node.children[side]
+		room := b.nodes[c].capacity(node.saddle) - b.nodes[c].volume
+		if room > amount {
+			room = amount
+		}
+		if room > 0 {
+			b.pour(c, room, inlet)
+			amount -= room
+		}
+		side = 1 - side
+		inlet = node.inlets[side]
+	}
+}
+
+// settle spreads the water of a basin over its cells.
+func (b *basins) settle(n int) {
+	node := &b.nodes[n]
+	if node.children[0] >= 0 && node.volume < b.full(n) {
+		b.settle(node.children[0])
+		b.settle(node.children[1])
+		return
+	}
+
+	// The basin holds a single lake.
+	// Every cell below the saddle is under water, and the cells of the
+	// basin itself flood from the lowest up until the water runs out.
+	lake := b.gather(n, nil)
+	flooded := node.count - len(node.cells)
+	floor := node.floor
+	for _, i := range node.cells {
+		floor -= b.elevation(i)
+	}
+	for _, i := range node.cells {
+		e := b.elevation(i)
+		if flooded > 0 && flooded*e-floor > node.volume {
+			break
+		}
+		flooded++
+		floor += e
+	}
+	level := floorDiv(node.volume+floor, flooded)
+	extra := node.volume + floor - level*flooded
+	for k, i := range lake[:flooded] {
+		c := b.cell(i)
+		c.Water = level - c.SurfaceElevation
+		if k < extra {
+			c.Water++
+		}
+	}
+}
+
+// gather collects the cells of a basin, with the cells of the basins below
+// it first.
+func (b *basins) gather(n int, cells []int) []int {
+	node := &b.nodes[n]
+	for _, c := range node.children {
+		if c >= 0 {
+			cells = b.gather(c, cells)
+		}
+	}
+	return append(cells, node.cells...)
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if q*b > a {
+		q--
+	}
+	return q
+}
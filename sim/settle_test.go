@@ -0,0 +1,68 @@
+package sim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func totalWaterOf(w *World) (total int) {
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			total += w.Field[x][y].Water
+		}
+	}
+	return
+}
+
+func TestSettleConservesWater(t *testing.T) {
+	w := NewWorld()
+	before := totalWaterOf(w)
+	Settle(w)
+	assert.Equal(t, before, totalWaterOf(w))
+}
+
+func TestSettleLevelsLakes(t *testing.T) {
+	w := NewWorld()
+	Settle(w)
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			c := &w.Field[x][y]
+			assert.True(t, c.Water >= 0)
+			if c.Water == 0 {
+				continue
+			}
+			for _, n := range []*Cell{
+				&w.Field[x][(y+w.Height-1)%w.Height],
+				&w.Field[x][(y+1)%w.Height],
+				&w.Field[(x+w.Width-1)%w.Width][y],
+				&w.Field[(x+1)%w.Width][y],
+			} {
+				assert.True(t, n.WaterElevation >= c.WaterElevation-1, "water should not stand above lower ground")
+			}
+		}
+	}
+}
+
+func TestSettleSpillsIntoNextBasin(t *testing.T) {
+	w := &World{Width: width, Height: height, Field: NewField(width, height)}
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			w.Field[x][y].SurfaceElevation = 100
+		}
+	}
+	// Two square basins, one holding more than it can.
+	for x := 10; x < 20; x++ {
+		for y := 10; y < 20; y++ {
+			w.Field[x][y].SurfaceElevation = 0
+			w.Field[x+40][y].SurfaceElevation = 50
+		}
+	}
+	w.Field[15][15].Water = 12000
+
+	Settle(w)
+	assert.Equal(t, 12000, totalWaterOf(w))
+	assert.Equal(t, 100, w.Field[12][12].Water)
+	assert.Equal(t, 20, w.Field[52][12].Water)
+	assert.Equal(t, 0, w.Field[80][80].Water)
+}
@@ -0,0 +1,176 @@
+package sim
+
+import "math"
+
+// ShallowWater integrates the two-dimensional shallow water equations, with
+// the depth of water at the center of each cell and its velocity across the
+// faces between cells.
+// Water carries momentum from tick to tick, so waves, sloshing and currents
+// can form.
+// Each tick divides into as many steps as the speed of the fastest wave
+// requires to remain stable.
+// The advection of momentum is neglected.
+type ShallowWater struct {
+	// Gravity accelerates water down the slope of its surface, in cells
+	// per tick squared per unit of elevation, 0.01 if zero.
+	Gravity float64
+	// Friction is the fraction of its velocity that water loses each tick,
+	// 0.01 if zero.
+	Friction float64
+	// Drag slows water in proportion to its speed over its depth, so that
+	// thin sheets of water do not race down slopes, 1 if zero.
+	Drag float64
+	// MaxSpeed limits the speed of water, in cells per tick, 1 if zero.
+	MaxSpeed float64
+
+	water surfaceWater
+	// velocity across the east and south face of each cell
+	u, v []float64
+}
+
+func (s *ShallowWater) Flow(next, prev *World) {
+	gravity := s.Gravity
+	if gravity == 0 {
+		gravity = 0.01
+	}
+	friction := s.Friction
+	if friction == 0 {
+		friction = 0.01
+	}
+	drag := s.Drag
+	if drag == 0 {
+		drag = 1
+	}
+	maxSpeed := s.MaxSpeed
+	if maxSpeed == 0 {
+		maxSpeed = 1
+	}
+
+	s.water.load(prev)
+	g := s.water.grid
+	h := s.water.depth
+	if len(s.u) != g.n {
+		s.u = make([]float64, g.n)
+		s.v = make([]float64, g.n)
+	}
+
+	ground := make([]float64, g.n)
+	for x := 0; x < g.width; x++ {
+		for y := 0; y < g.height; y++ {
+			ground[g.at(x, y)] = float64(prev.Field[x][y].SurfaceElevation)
+		}
+	}
+
+	east := make([]float64, g.n)
+	south := make([]float64, g.n)
+	step := shallowStep{
+		grid:     g,
+		ground:   ground,
+		depth:    h,
+		u:        s.u,
+		v:        s.v,
+		gravity:  gravity,
+		friction: friction,
+		drag:     drag,
+		maxSpeed: maxSpeed,
+		east:     east,
+		south:    south,
+	}
+	for remaining := 1.0; remaining > 0; {
+		// The fastest wave may cross at most half a cell in each step.
+		fastest := 0.0
+		for i := range h {
+			fastest = math.Max(fastest, math.Sqrt(gravity*h[i])+math.Max(math.Abs(s.u[i]), math.Abs(s.v[i])))
+		}
+		dt := remaining
+		if fastest*dt > 0.5 {
+			dt = 0.5 / fastest
+		}
+		step.advance(dt)
+		remaining -= dt
+	}
+
+	s.water.store(next)
+	reportFlow(next, prev, g, east, south)
+}
+
+type shallowStep struct {
+	grid
+	ground, depth, u, v               []float64
+	gravity, friction, drag, maxSpeed float64
+	east, south                       []float64 // volume crossing each face over the tick
+}
+
+func (s *shallowStep) advance(dt float64) {
+	h := s.depth
+	surface := make([]float64, s.n)
+	for i := range surface {
+		surface[i] = s.ground[i] + h[i]
+	}
+
+	// Accelerate water across each face down the slope of the surface,
+	// carrying the depth of water on the upstream side.
+	fe := make([]float64, s.n)
+	fs := make([]float64, s.n)
+	face := func(velocity []float64, flux []float64, i, j int) {
+		u := velocity[i] - s.gravity*dt*(surface[j]-surface[i])
+		depth := h[i]
+		if u < 0 {
+			depth = h[j]
+		}
+		if depth <= 0 {
+			velocity[i] = 0
+			return
+		}
+		u /= 1 + dt*(s.friction+s.drag*math.Abs(u)/depth)
+		u = math.Max(-s.maxSpeed, math.Min(s.maxSpeed, u))
+		velocity[i] = u
+		flux[i] = u * depth * dt
+	}
+	for x := 0; x < s.width; x++ {
+		for y := 0; y < s.height; y++ {
+			i := s.at(x, y)
+			face(s.u, fe, i, s.at((x+1)%s.width, y))
+			face(s.v, fs, i, s.at(x, (y+1)%s.height))
+		}
+	}
+
+	// No cell may give more water than it holds.
+	scale := make([]float64, s.n)
+	for x := 0; x < s.width; x++ {
+		for y := 0; y < s.height; y++ {
+			i := s.at(x, y)
+			w, n := s.at((x+s.width-1)%s.width, y), s.at(x, (y+s.height-1)%s.height)
+			out := math.Max(0, fe[i]) + math.Max(0, fs[i]) + math.Max(0, -fe[w]) + math.Max(0, -fs[n])
+			scale[i] = 1
+			if out > h[i] {
+				scale[i] = h[i] / out
+			}
+		}
+	}
+	limit := func(velocity, flux []float64, i, j int) {
+		k := scale[i]
+		if flux[i] < 0 {
+			k = scale[j]
+		}
+		flux[i] *= k
+		velocity[i] *= k
+	}
+	for x := 0; x < s.width; x++ {
+		for y := 0; y < s.height; y++ {
+			i := s.at(x, y)
+			limit(s.u, fe, i, s.at((x+1)%s.width, y))
+			limit(s.v, fs, i, s.at(x, (y+1)%s.height))
+		}
+	}
+
+	for x := 0; x < s.width; x++ {
+		for y := 0; y < s.height; y++ {
+			i := s.at(x, y)
+			w, n := s.at((x+s.width-1)%s.width, y), s.at(x, (y+s.height-1)%s.height)
+			h[i] = math.Max(0, h[i]-fe[i]-fs[i]+fe[w]+fs[n])
+			s.east[i] += fe[i]
+			s.south[i] += fs[i]
+		}
+	}
+}
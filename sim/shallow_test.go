@@ -0,0 +1,69 @@
+package sim
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// walledBasin returns a world with a flat floor walled in from the rest of the
+// torus, and a column of water standing in one corner of the floor.
+func walledBasin() *World {
+	w := &World{Width: 12, Height: 10, Field: NewField(12, 10)}
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			c := &w.Field[x][y]
+			if x == 0 || y == 0 {
+				c.SurfaceElevation = 1000
+			}
+		}
+	}
+	w.Field[2][2].Water = 400
+	w.HighestSurfaceElevation = 1000
+	return w
+}
+
+// flows runs a hydrology over a world, checking that it neither makes nor
+// loses water and that no cell holds less than none.
+func flows(t *testing.T, h Hydrology, w *World, ticks int) *World {
+	s := NewSimulation(w)
+	s.Hydrology = h
+	before := totalWaterOf(w)
+	for tick := 0; tick < ticks; tick++ {
+		s.Step()
+		for x := 0; x < w.Width; x++ {
+			for y := 0; y < w.Height; y++ {
+				if s.Next.Field[x][y].Water < 0 {
+					t.Fatalf("%T left %d water at %d, %d", h, s.Next.Field[x][y].Water, x, y)
+				}
+			}
+		}
+		assert.InDelta(t, before, totalWaterOf(s.Next), 1)
+	}
+	return s.Next
+}
+
+// assertLevel checks that the water over the floor of a basin lies flat.
+func assertLevel(t *testing.T, w *World) {
+	lo, hi := 1<<30, -1<<30
+	for x := 1; x < w.Width; x++ {
+		for y := 1; y < w.Height; y++ {
+			c := &w.Field[x][y]
+			level := c.SurfaceElevation + c.Water
+			if level < lo {
+				lo = level
+			}
+			if level > hi {
+				hi = level
+			}
+		}
+	}
+	assert.True(t, hi-lo <= 1, fmt.Sprint("the water is level from ", lo, " to ", hi))
+}
+
+func TestShallowWaterSettlesFlat(t *testing.T) {
+	w := flows(t, &ShallowWater{}, walledBasin(), 2000)
+	assertLevel(t, w)
+	assert.Equal(t, 0, w.Field[0][5].Water)
+}
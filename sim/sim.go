@@ -23,8 +23,11 @@ type Cell struct {
 	WaterElevation   int // Absolute height of water column
 	WaterShed        uint8
 	WaterSpeed       int
-	// WaterDX     int
-	// WaterDY     int
+	WaterDX          int // Net flow of water east across the cell
+	WaterDY          int // Net flow of water south across the cell
+	SoilMoisture     int // Water held in the soil
+	GroundWater      int // Water held in the aquifer under the soil
+	WaterTable       int // Absolute height of the water in the aquifer
 	// WaterHeat int
 	// Steam     int
 	// SteamHeat int
@@ -32,7 +35,17 @@ type Cell struct {
 	// AirHeat   int
 }
 
-type Field [width][height]Cell
+type Field [][]Cell
+
+// NewField returns a field of cells with the given dimensions.
+func NewField(width, height int) Field {
+	cells := make([]Cell, width*height)
+	f := make(Field, width)
+	for x := range f {
+		f[x] = cells[x*height : (x+1)*height : (x+1)*height]
+	}
+	return f
+}
 
 type World struct {
 	Height int
@@ -55,10 +68,28 @@ type World struct {
 	Field      Field
 }
 
-func Reset(w *World) {
-	w.Height = height
-	w.Width = width
+// Terrain describes how to generate a world.
+type Terrain struct {
+	// Seed offsets the seeds of every octave of noise that shapes the
+	// terrain.
+	Seed   int64
+	Width  int
+	Height int
+}
 
+// DefaultTerrain is the terrain of NewWorld.
+var DefaultTerrain = Terrain{Width: width, Height: height}
+
+// Generate returns a new world with this terrain.
+func (t Terrain) Generate() *World {
+	world := &World{}
+	t.Reset(world)
+	return world
+}
+
+// Reset replaces a world with this terrain, covered evenly in water.
+func (t Terrain) Reset(w *World) {
+	width, height := t.Width, t.Height
 	scales := []struct {
 		seed                       int64
 		terrainScale, simplexScale float64
@@ -81,39 +112,66 @@ func Reset(w *World) {
 			source Source
 		}{
 			scale:  s.terrainScale * 10,
-			source: NewTesselation(NewScale(opensimplex.NewWithSeed(s.seed), s.simplexScale), width, height),
+			source: NewTesselation(NewScale(opensimplex.NewWithSeed(s.seed+t.Seed), s.simplexScale), float64(width), float64(height)),
 		})
 	}
 
+	w.resetTerrain(width, height, func(x, y int) int {
+		l := 0.0
+		for _, n := range noises {
+			l += n.scale * n.source.Eval2(float64(x), float64(y))
+		}
+		return int(l)
+	})
+}
+
+// resetTerrain replaces a world with terrain of the given dimensions and
+// elevations, covered evenly in water.
+// The extent of the elevations of the world reaches sea level.
+func (w *World) resetTerrain(width, height int, elevation func(x, y int) int) {
+	w.Height = height
+	w.Width = width
+	if len(w.Field) != width || width > 0 && len(w.Field[0]) != height {
+		w.Field = NewField(width, height)
+	}
 	w.LowestSurfaceElevation = 0
 	w.HighestSurfaceElevation = 0
 	for x := 0; x < width; x++ {
 		for y := 0; y < height; y++ {
-			l := 0.0
-			for _, n := range noises {
-				l += n.scale * n.source.Eval2(float64(x), float64(y))
-			}
-			el := int(l)
+			el := elevation(x, y)
 			if el > w.HighestSurfaceElevation {
 				w.HighestSurfaceElevation = el
 			}
 			if el < w.LowestSurfaceElevation {
 				w.LowestSurfaceElevation = el
 			}
-			c := &w.Field[x][y]
-			c.SurfaceElevation = el
-			c.Water = totalWater / width / height
+			w.Field[x][y] = Cell{
+				SurfaceElevation: el,
+				Water:            initialWater,
+			}
 		}
 	}
 }
 
+func Reset(w *World) {
+	DefaultTerrain.Reset(w)
+}
+
 func NewWorld() *World {
-	world := &World{}
-	Reset(world)
-	return world
+	return DefaultTerrain.Generate()
 }
 
-func manhattan(x1, y1, x2, y2 int) int {
+// Clone returns a copy of a world that shares none of its cells.
+func (w *World) Clone() *World {
+	c := *w
+	c.Field = NewField(w.Width, w.Height)
+	for x := range c.Field {
+		copy(c.Field[x], w.Field[x])
+	}
+	return &c
+}
+
+func manhattan(x1, y1, x2, y2, width, height int) int {
 	dx := x2 - x1
 	if dx < 0 {
 		dx = -dx
@@ -132,126 +190,87 @@ func manhattan(x1, y1, x2, y2 int) int {
 	return d
 }
 
+// Sun returns the cell under the sun at tick t.
+// The sun crosses a world from east to west over the equator once every
+// width ticks.
+func Sun(width, height, t int) (x, y int) {
+	return (width - t%width) % width, height / 2
+}
+
+// bathymetry recalculates the water surface and its extrema.
+func bathymetry(w *World) {
+	w.Wettest = 0
+	w.HighestWaterElevation = 0
+	w.LowestWaterElevation = 1000000000
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			c := &w.Field[x][y]
+			c.WaterElevation = c.SurfaceElevation + c.Water
+			if c.WaterElevation > w.HighestWaterElevation {
+				w.HighestWaterElevation = c.WaterElevation
+			}
+			if c.WaterElevation < w.LowestWaterElevation {
+				w.LowestWaterElevation = c.WaterElevation
+			}
+			if c.Water > w.Wettest {
+				w.Wettest = c.Water
+			}
+		}
+	}
+}
+
+// Tick advances the world from prev to next with the default processes.
 func Tick(next, prev *World, t int) {
+	(&Simulation{}).tick(next, prev, t)
+}
+
+func (s *Simulation) tick(next, prev *World, t int) {
+	width, height := prev.Width, prev.Height
+	if next.Width != width || next.Height != height || next.Field == nil {
+		next.Width, next.Height = width, height
+		next.Field = NewField(width, height)
+	}
 	next.HighestSurfaceElevation = prev.HighestSurfaceElevation
 	next.LowestSurfaceElevation = prev.LowestSurfaceElevation
-	next.MostRapidWater = 0
 
-	sx := width - (t % width)
-	sy := height / 2
+	sx, sy := Sun(width, height, t)
 
 	// Reset
 	for x := 0; x < width; x++ {
-		for y := 0; y < width; y++ {
+		for y := 0; y < height; y++ {
 			// Compute water gradient
 			pc := &prev.Field[x][y]
 			nc := &next.Field[x][y]
 			pc.WaterElevation = pc.SurfaceElevation + pc.Water
 			nc.SurfaceElevation = pc.SurfaceElevation
 			nc.Water = pc.Water
+			nc.SoilMoisture = pc.SoilMoisture
+			nc.GroundWater = pc.GroundWater
+			nc.WaterTable = pc.WaterTable
 		}
 	}
 
 	// Distribute water
-	for x := 0; x < width; x++ {
-		for y := 0; y < width; y++ {
-			// Compute water gradient
-			// {prev,next}cell{north,south,east,west}
-			pc := &prev.Field[x][y]
-			pcn := &prev.Field[x][(y+height-1)%height]
-			pcs := &prev.Field[x][(y+1)%height]
-			pcw := &prev.Field[(x+width-1)%width][y]
-			pce := &prev.Field[(x+1)%width][y]
-
-			nc := &next.Field[x][y]
-			ncn := &next.Field[x][(y+height-1)%height]
-			ncs := &next.Field[x][(y+1)%height]
-			ncw := &next.Field[(x+width-1)%width][y]
-			nce := &next.Field[(x+1)%width][y]
-
-			pt := pc
-			nt := nc
-			nc.WaterShed = pc.WaterShed
-			if pcn.WaterElevation < pt.WaterElevation {
-				pt = pcn
-				nt = ncn
-				nc.WaterShed = 1
-			}
-			if pcs.WaterElevation < pt.WaterElevation {
-				pt = pcs
-				nt = ncs
-				nc.WaterShed = 2
-			}
-			if pcw.WaterElevation < pt.WaterElevation {
-				pt = pcw
-				nt = ncw
-				nc.WaterShed = 3
-			}
-			if pce.WaterElevation < pt.WaterElevation {
-				pt = pce
-				nt = nce
-				nc.WaterShed = 4
-			}
-
-			equilibrium := pc.WaterElevation/2 + pt.WaterElevation/2
-			delta := pc.WaterElevation - equilibrium
-			if delta > pc.Water {
-				delta = pc.Water
-			}
-			// dampen water flow
-			if delta > 3 {
-				delta = delta / 3
-			}
-			nt.Water += delta
-			nc.Water -= delta
-
-			nc.WaterSpeed = delta
-			if nc.WaterSpeed > next.MostRapidWater {
-				next.MostRapidWater = nc.WaterSpeed
-			}
-
-		}
+	s.hydrology().Flow(next, prev)
+	if s.Groundwater != nil {
+		s.Groundwater.Seep(next)
 	}
 
-	// Bathymetry
-	next.Wettest = 0
-	next.HighestWaterElevation = 0
-	next.LowestWaterElevation = 1000000000
-	for x := 0; x < width; x++ {
-		for y := 0; y < width; y++ {
-			// Compute water gradient
-			nc := &next.Field[x][y]
-			nc.WaterElevation = nc.SurfaceElevation + nc.Water
-			if nc.WaterElevation > next.HighestWaterElevation {
-				next.HighestWaterElevation = nc.WaterElevation
-			}
-			if nc.WaterElevation < next.LowestWaterElevation {
-				next.LowestWaterElevation = nc.WaterElevation
-			}
-			if nc.Water > next.Wettest {
-				next.Wettest = nc.Water
-			}
-		}
-	}
+	bathymetry(next)
 
 	// Distribute heat
+	s.heat().Diffuse(next, prev)
 	next.HottestSurface = 0
 	next.BrightestSurface = 0
 	for x := 0; x < width; x++ {
-		for y := 0; y < width; y++ {
+		for y := 0; y < height; y++ {
 			nc := &next.Field[x][y]
-			pc := &prev.Field[x][y]
-			pcn := &prev.Field[x][(y+height-1)%height]
-			pcs := &prev.Field[x][(y+1)%height]
-			pcw := &prev.Field[(x+width-1)%width][y]
-			pce := &prev.Field[(x+1)%width][y]
 
-			// diffuse heat from prior turn
-			heat := (pcn.SurfaceHeat + pcs.SurfaceHeat + pce.SurfaceHeat + pcw.SurfaceHeat + pc.SurfaceHeat) / 5
-			// heat := pc.SurfaceHeat
+			// heat diffused from prior turn
+			heat := nc.SurfaceHeat
 
 			// distribute heat according to the distance from direct sunlight
-			d := manhattan(sx, sy, x, y)
+			d := manhattan(sx, sy, x, y, width, height)
 			dh := width*3/5 - d
 			if dh < 0 {
 				dh = 0
@@ -0,0 +1,48 @@
+package sim
+
+// Simulation advances a world through time, alternating between two
+// buffers, and selects the solver for each process.
+// The zero value of each solver selects the process that Tick uses.
+type Simulation struct {
+	Next, Prev *World
+	T          int
+
+	// Hydrology moves water over the surface.
+	Hydrology Hydrology
+	// Groundwater, if any, soaks surface water into the soil and an
+	// aquifer below it.
+	Groundwater *Groundwater
+	// Heat spreads surface heat between cells.
+	Heat HeatSolver
+	// Tracers, if any, drift with the water after each tick.
+	Tracers *Tracers
+}
+
+// NewSimulation returns a simulation that starts from the given world.
+func NewSimulation(w *World) *Simulation {
+	return &Simulation{Next: w, Prev: w.Clone()}
+}
+
+// Step advances the simulation by one tick.
+func (s *Simulation) Step() {
+	s.Next, s.Prev = s.Prev, s.Next
+	s.tick(s.Next, s.Prev, s.T)
+	if s.Tracers != nil {
+		s.Tracers.Advect(s.Next)
+	}
+	s.T++
+}
+
+func (s *Simulation) heat() HeatSolver {
+	if s.Heat == nil {
+		return ExplicitHeat{}
+	}
+	return s.Heat
+}
+
+func (s *Simulation) hydrology() Hydrology {
+	if s.Hydrology == nil {
+		return GreedyFlow{}
+	}
+	return s.Hydrology
+}
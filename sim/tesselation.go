@@ -24,7 +24,7 @@ func (t *tesselation) Eval2(x, y float64) float64 {
 	c := t.source.Eval2(float64(x), float64(y-t.height))
 	d := t.source.Eval2(float64(x-t.width), float64(y-t.height))
 	cd := c*(1.0-float64(x)/t.width) + d*(float64(x)/t.width)
-	return ab*(1.0-float64(y)/height) + cd*float64(y)/height
+	return ab*(1.0-float64(y)/t.height) + cd*float64(y)/t.height
 }
 
 func NewScale(source Source, s float64) Source {
@@ -0,0 +1,116 @@
+package sim
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"math/rand"
+	"strconv"
+)
+
+// Tracer is a passive particle that drifts with the water.
+// Positions are in cells from the corner of the world, where the center of
+// a cell is half a cell from its corner, and do not wrap around the torus,
+// so a trajectory stays continuous where it crosses an edge.
+type Tracer struct {
+	X, Y float64
+	// Seeded is the number of ticks the tracers had advected when the
+	// tracer was seeded.
+	Seeded int
+	// Trajectory is the position of the tracer when it was seeded and
+	// every Every ticks since.
+	Trajectory [][2]float64
+}
+
+// Tracers advects tracer particles with the flow of water through the cells
+// under them, and records their trajectories.
+type Tracers struct {
+	Tracers []*Tracer
+	// Every is the number of ticks between the positions of trajectories,
+	// 1 if zero.
+	Every int
+	t     int
+}
+
+func (ts *Tracers) every() int {
+	if ts.Every <= 0 {
+		return 1
+	}
+	return ts.Every
+}
+
+// SeedPoint adds a tracer at a position.
+func (ts *Tracers) SeedPoint(x, y float64) {
+	ts.Tracers = append(ts.Tracers, &Tracer{
+		X:          x,
+		Y:          y,
+		Seeded:     ts.t,
+		Trajectory: [][2]float64{{x, y}},
+	})
+}
+
+// SeedLine adds n tracers evenly along a line, including its ends.
+func (ts *Tracers) SeedLine(x0, y0, x1, y1 float64, n int) {
+	for i := 0; i < n; i++ {
+		f := 0.5
+		if n > 1 {
+			f = float64(i) / float64(n-1)
+		}
+		ts.SeedPoint(x0+(x1-x0)*f, y0+(y1-y0)*f)
+	}
+}
+
+// SeedRandom adds n tracers at random positions in a world.
+func (ts *Tracers) SeedRandom(w *World, n int, r *rand.Rand) {
+	for i := 0; i < n; i++ {
+		ts.SeedPoint(r.Float64()*float64(w.Width), r.Float64()*float64(w.Height))
+	}
+}
+
+// Advect moves every tracer by the velocity of the water in the cell under
+// it, which is the flow of water across the cell over the depth of its
+// column, at most a cell each tick.
+func (ts *Tracers) Advect(w *World) {
+	for _, tr := range ts.Tracers {
+		x := int(math.Floor(tr.X))
+		y := int(math.Floor(tr.Y))
+		c := &w.Field[(x%w.Width+w.Width)%w.Width][(y%w.Height+w.Height)%w.Height]
+		if c.Water > 0 {
+			tr.X += clamp(float64(c.WaterDX)/float64(c.Water), -1, 1)
+			tr.Y += clamp(float64(c.WaterDY)/float64(c.Water), -1, 1)
+		}
+	}
+	ts.t++
+	for _, tr := range ts.Tracers {
+		if (ts.t-tr.Seeded)%ts.every() == 0 {
+			tr.Trajectory = append(tr.Trajectory, [2]float64{tr.X, tr.Y})
+		}
+	}
+}
+
+// WriteCSV writes the trajectories of the tracers as comma separated values
+// with a header: the index of each tracer, the tick, and its position.
+func (ts *Tracers) WriteCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	if err := w.Write([]string{"tracer", "tick", "x", "y"}); err != nil {
+		return err
+	}
+	for i, tr := range ts.Tracers {
+		for j, p := range tr.Trajectory {
+			if err := w.Write([]string{
+				strconv.Itoa(i),
+				strconv.Itoa(tr.Seeded + j*ts.every()),
+				strconv.FormatFloat(p[0], 'f', -1, 64),
+				strconv.FormatFloat(p[1], 'f', -1, 64),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
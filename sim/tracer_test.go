@@ -0,0 +1,31 @@
+package sim
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracersDriftWithTheWater(t *testing.T) {
+	w := &World{Width: 4, Height: 4, Field: NewField(4, 4)}
+	for x := 0; x < 4; x++ {
+		for y := 0; y < 4; y++ {
+			w.Field[x][y] = Cell{Water: 10, WaterDX: 5, WaterDY: -20}
+		}
+	}
+	ts := &Tracers{Every: 2}
+	ts.SeedPoint(3.5, 0.5)
+	for i := 0; i < 4; i++ {
+		ts.Advect(w)
+	}
+
+	// Half a cell east and at most a cell north each tick, across the
+	// edges of the torus.
+	tr := ts.Tracers[0]
+	assert.Equal(t, [][2]float64{{3.5, 0.5}, {4.5, -1.5}, {5.5, -3.5}}, tr.Trajectory)
+
+	var out bytes.Buffer
+	assert.NoError(t, ts.WriteCSV(&out))
+	assert.Equal(t, "tracer,tick,x,y\n0,0,3.5,0.5\n0,2,4.5,-1.5\n0,4,5.5,-3.5\n", out.String())
+}
@@ -1,18 +1,8 @@
+// Command topo is an alias for bottle topo.
 package main
 
-import (
-	"image/color"
-
-	"github.com/kriskowal/bottle-world/sim"
-	"github.com/kriskowal/bottle-world/viz"
-)
+import "github.com/kriskowal/bottle-world/cli"
 
 func main() {
-	w := sim.NewWorld()
-
-	breadth := float64(w.HighestSurfaceElevation - w.LowestSurfaceElevation)
-	viz.Write(w, "topo.gif", viz.NewGrayScale(), func(c *sim.Cell) color.Color {
-		z := uint8(float64(c.SurfaceElevation-w.LowestSurfaceElevation) / breadth * 0xff)
-		return viz.Gray(z)
-	})
+	cli.Alias("topo")
 }
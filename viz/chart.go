@@ -0,0 +1,285 @@
+package viz
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/kriskowal/bottle-world/viz/colormap"
+	"github.com/kriskowal/bottle-world/viz/font"
+)
+
+// Series records statistics of a world over a run.
+type Series struct {
+	Stats []*Stat
+	Ticks []int
+	// Values are the values of each statistic at each tick.
+	Values [][]float64
+}
+
+// NewSeries returns an empty series of statistics.
+func NewSeries(stats ...*Stat) *Series {
+	return &Series{Stats: stats, Values: make([][]float64, len(stats))}
+}
+
+// Record adds the statistics of a world after some ticks.
+func (s *Series) Record(w *sim.World, tick int) {
+	s.Ticks = append(s.Ticks, tick)
+	for i, stat := range s.Stats {
+		s.Values[i] = append(s.Values[i], stat.Value(w))
+	}
+}
+
+// Only returns a series of some of the statistics of another, by name,
+// sharing its values.
+func (s *Series) Only(names ...string) *Series {
+	only := &Series{Ticks: s.Ticks}
+	for _, name := range names {
+		for i, stat := range s.Stats {
+			if stat.Name == name {
+				only.Stats = append(only.Stats, stat)
+				only.Values = append(only.Values, s.Values[i])
+			}
+		}
+	}
+	return only
+}
+
+// Chart draws a series as line charts stacked over a shared axis of ticks,
+// one for each statistic over its own range.
+type Chart struct {
+	Title string
+	// Width is the width of the chart in pixels, 640 if zero.
+	Width int
+	// Height is the height of the chart in pixels, or enough for 60 pixels
+	// for each statistic if zero.
+	Height int
+}
+
+// Image draws a chart in black over white.
+func (c Chart) Image(s *Series) *image.RGBA {
+	size := c.size(s)
+	img := image.NewRGBA(image.Rectangle{Max: size})
+	p := &raster{img}
+	p.rect(img.Bounds(), color.White)
+	c.draw(p, s, size, color.Black)
+	return img
+}
+
+// WriteSVG writes a chart in black over white as scalable vector graphics.
+func (c Chart) WriteSVG(out io.Writer, s *Series) error {
+	size := c.size(s)
+	p := &vector{}
+	p.rect(image.Rectangle{Max: size}, color.White)
+	c.draw(p, s, size, color.Black)
+	_, err := fmt.Fprintf(out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"monospace\" font-size=\"%d\">\n%s</svg>\n",
+		size.X, size.Y, font.Height+2, p.body)
+	return err
+}
+
+const (
+	chartAxis = 10 * font.Advance
+	chartGap  = 4
+)
+
+func (c Chart) size(s *Series) image.Point {
+	size := image.Pt(c.Width, c.Height)
+	if size.X == 0 {
+		size.X = 640
+	}
+	if size.Y == 0 {
+		size.Y = 60*len(s.Stats) + 2*font.Leading + 2*chartGap
+		if c.Title != "" {
+			size.Y += font.Leading
+		}
+	}
+	return size
+}
+
+// plotter draws the lines, rectangles and text of a chart.
+type plotter interface {
+	polyline(points [][2]float64, c color.Color)
+	rect(r image.Rectangle, c color.Color)
+	// text draws text with its top left at a point.
+	text(at image.Point, s string, c color.Color)
+}
+
+func (c Chart) draw(p plotter, s *Series, size image.Point, ink color.Color) {
+	faint := color.RGBA{0xd0, 0xd0, 0xd0, 0xff}
+	top := chartGap
+	if c.Title != "" {
+		p.text(image.Pt(chartGap, top), c.Title, ink)
+		top += font.Leading
+	}
+	plot := image.Rect(chartAxis, top, size.X-chartGap, size.Y-font.Leading-chartGap)
+	if len(s.Stats) == 0 || len(s.Ticks) == 0 || plot.Empty() {
+		return
+	}
+	t0, t1 := float64(s.Ticks[0]), float64(s.Ticks[len(s.Ticks)-1])
+	if t1 == t0 {
+		t1 = t0 + 1
+	}
+	x := func(t float64) float64 {
+		return float64(plot.Min.X) + (t-t0)/(t1-t0)*float64(plot.Dx()-1)
+	}
+
+	// The axis of ticks, with a line down through every chart.
+	for _, t := range colormap.Ticks(t0, t1, plot.Dx()/80+1) {
+		px := x(t)
+		p.polyline([][2]float64{{px, float64(plot.Min.Y)}, {px, float64(plot.Max.Y)}}, faint)
+		label := strconv.FormatFloat(t, 'f', -1, 64)
+		w := font.Measure(label).X
+		p.text(image.Pt(int(px)-w/2, plot.Max.Y+2), label, ink)
+	}
+
+	row := plot.Dy() / len(s.Stats)
+	for i, stat := range s.Stats {
+		r := image.Rect(plot.Min.X, plot.Min.Y+i*row, plot.Max.X, plot.Min.Y+(i+1)*row-chartGap)
+		lo, hi := extent(s.Values[i])
+		if hi == lo {
+			lo, hi = lo-1, hi+1
+		}
+		y := func(v float64) float64 {
+			return float64(r.Max.Y-1) - (v-lo)/(hi-lo)*float64(r.Dy()-1)
+		}
+		p.polyline([][2]float64{
+			{float64(r.Min.X), float64(r.Min.Y)},
+			{float64(r.Min.X), float64(r.Max.Y - 1)},
+			{float64(r.Max.X - 1), float64(r.Max.Y - 1)},
+		}, ink)
+		points := make([][2]float64, len(s.Ticks))
+		for j, t := range s.Ticks {
+			points[j] = [2]float64{x(float64(t)), y(s.Values[i][j])}
+		}
+		p.polyline(points, lineColor(i))
+		p.text(r.Min.Add(image.Pt(chartGap, 0)), stat.Name, ink)
+		for _, v := range []float64{lo, hi} {
+			label := strconv.FormatFloat(v, 'g', 4, 64)
+			w := font.Measure(label).X
+			p.text(image.Pt(r.Min.X-w-2, int(y(v))-font.Height/2), label, ink)
+		}
+	}
+}
+
+func extent(values []float64) (lo, hi float64) {
+	lo, hi = math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	return lo, hi
+}
+
+// raster plots on an image.
+type raster struct {
+	dst draw.Image
+}
+
+func (r *raster) polyline(points [][2]float64, c color.Color) {
+	for i := 1; i < len(points); i++ {
+		line(r.dst, points[i-1][0], points[i-1][1], points[i][0], points[i][1], c)
+	}
+	if len(points) == 1 {
+		line(r.dst, points[0][0], points[0][1], points[0][0], points[0][1], c)
+	}
+}
+
+func (r *raster) rect(rect image.Rectangle, c color.Color) {
+	draw.Draw(r.dst, rect, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func (r *raster) text(at image.Point, s string, c color.Color) {
+	font.Draw(r.dst, at, s, c)
+}
+
+// vector plots as the elements of scalable vector graphics.
+type vector struct {
+	body []byte
+}
+
+func (v *vector) polyline(points [][2]float64, c color.Color) {
+	v.body = append(v.body, `<polyline fill="none" stroke="`+hexColor(c)+`" points="`...)
+	for i, p := range points {
+		if i > 0 {
+			v.body = append(v.body, ' ')
+		}
+		// Pixels are centered half a pixel from their corners.
+		v.body = strconv.AppendFloat(v.body, p[0]+0.5, 'f', 2, 64)
+		v.body = append(v.body, ',')
+		v.body = strconv.AppendFloat(v.body, p[1]+0.5, 'f', 2, 64)
+	}
+	v.body = append(v.body, "\"/>\n"...)
+}
+
+func (v *vector) rect(r image.Rectangle, c color.Color) {
+	v.body = append(v.body, fmt.Sprintf("<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n",
+		r.Min.X, r.Min.Y, r.Dx(), r.Dy(), hexColor(c))...)
+}
+
+func (v *vector) text(at image.Point, s string, c color.Color) {
+	v.body = append(v.body, fmt.Sprintf("<text x=\"%d\" y=\"%d\" fill=\"%s\">%s</text>\n",
+		at.X, at.Y+font.Height, hexColor(c), html.EscapeString(s))...)
+}
+
+func hexColor(c color.Color) string {
+	rgba := color.RGBAModel.Convert(c).(color.RGBA)
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B)
+}
+
+// WithSparklines returns a frame extended below with a line for each
+// statistic of a series so far, over its own range, labeled with its name
+// and latest value.
+func WithSparklines(img image.Image, s *Series) *image.RGBA {
+	const row = font.Leading + 2
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(b.Min.X, b.Min.Y, b.Max.X, b.Max.Y+row*len(s.Stats)+margin))
+	draw.Draw(out, out.Bounds(), image.NewUniform(outline), image.Point{}, draw.Src)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+	p := &raster{out}
+	// Names take the first third of the width, and values as much as a
+	// number takes at the end.
+	names := b.Min.X + margin
+	lines := image.Rect(b.Min.X+b.Dx()/3, 0, b.Max.X-2*margin-font.Measure("-1.234e+05").X, 0)
+	for i, stat := range s.Stats {
+		top := b.Max.Y + margin + i*row
+		name := stat.Name
+		if n := (lines.Min.X - names - margin) / font.Advance; len(name) > n && n > 0 {
+			name = name[:n]
+		}
+		p.text(image.Pt(names, top), name, ink)
+		values := s.Values[i]
+		if len(values) == 0 {
+			continue
+		}
+		p.text(image.Pt(lines.Max.X+margin, top), strconv.FormatFloat(values[len(values)-1], 'g', 4, 64), ink)
+		lo, hi := extent(values)
+		if hi == lo {
+			lo, hi = lo-1, hi+1
+		}
+		// The whole run so far spans the width of the line.
+		points := make([][2]float64, len(values))
+		for j, v := range values {
+			x := float64(lines.Min.X)
+			if len(values) > 1 {
+				x += float64(j) / float64(len(values)-1) * float64(lines.Dx()-1)
+			}
+			points[j] = [2]float64{x, float64(top+font.Height-1) - (v-lo)/(hi-lo)*float64(font.Height-1)}
+		}
+		p.polyline(points, lineColor(i))
+	}
+	return out
+}
+
+// lineColor returns the color of a line of a chart or sparklines, from the
+// colorblind-safe Okabe-Ito set but for its black, which would vanish under
+// sparklines.
+func lineColor(i int) color.Color {
+	colors := colormap.OkabeIto.Colors
+	return colors[i%(len(colors)-1)]
+}
@@ -0,0 +1,39 @@
+package viz
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChart(t *testing.T) {
+	s := NewSeries(Stats["hottest-surface"], Stats["wettest"])
+	for tick := 0; tick < 10; tick++ {
+		s.Record(&sim.World{HottestSurface: tick * tick, Wettest: 5}, tick)
+	}
+	only := s.Only("wettest")
+	assert.Equal(t, []*Stat{Stats["wettest"]}, only.Stats)
+	assert.Equal(t, [][]float64{s.Values[1]}, only.Values)
+
+	img := Chart{Width: 200}.Image(s)
+	assert.Equal(t, 200, img.Bounds().Dx())
+
+	var out bytes.Buffer
+	assert.NoError(t, Chart{Title: "heat & water"}.WriteSVG(&out, s))
+	d := xml.NewDecoder(&out)
+	polylines := 0
+	for {
+		token, err := d.Token()
+		if err != nil {
+			break
+		}
+		if e, ok := token.(xml.StartElement); ok && e.Name.Local == "polyline" {
+			polylines++
+		}
+	}
+	// An axis and a line for each statistic, and a line for each tick.
+	assert.True(t, polylines > 4)
+}
@@ -0,0 +1,179 @@
+// Package colormap colors quantities with continuous color maps and
+// categorical color sets, and draws legends for them.
+//
+// The perceptual maps, viridis, magma and cividis, are uniform in lightness
+// so that equal steps of a quantity look like equal steps of color.
+// Viridis and cividis, the ColorBrewer diverging maps, and the Okabe-Ito
+// set are safe for readers with color vision deficiencies.
+package colormap
+
+import (
+	"image/color"
+	"math"
+	"sort"
+)
+
+// Map colors a quantity normalized to the range from 0 to 1, by
+// interpolating between evenly spaced colors.
+type Map struct {
+	Name        string
+	Description string
+	// Diverging maps have a neutral middle for quantities that vary either
+	// way from a center.
+	Diverging bool
+	// ColorblindSafe maps stay distinct under common color vision
+	// deficiencies.
+	ColorblindSafe bool
+	stops          []color.RGBA
+}
+
+// New returns a map through the given colors, evenly spaced.
+func New(name string, colors ...color.RGBA) *Map {
+	return &Map{Name: name, stops: colors}
+}
+
+// At returns the color of a normalized quantity.
+// Quantities outside the range take the color of the nearer end.
+func (m *Map) At(t float64) color.Color {
+	if t <= 0 || math.IsNaN(t) {
+		return m.stops[0]
+	}
+	last := len(m.stops) - 1
+	if t >= 1 {
+		return m.stops[last]
+	}
+	f := t * float64(last)
+	i := int(f)
+	f -= float64(i)
+	a, b := m.stops[i], m.stops[i+1]
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*f))
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), mix(a.A, b.A)}
+}
+
+// Palette samples a map at n evenly spaced points.
+func (m *Map) Palette(n int) color.Palette {
+	pal := make(color.Palette, n)
+	for i := range pal {
+		pal[i] = m.At(float64(i) / float64(n-1))
+	}
+	return pal
+}
+
+// Categorical is a set of distinct colors for quantities that are kinds
+// rather than amounts.
+type Categorical struct {
+	Name           string
+	ColorblindSafe bool
+	Colors         []color.RGBA
+}
+
+// At returns the color of a category, repeating the colors if there are
+// more categories than colors.
+func (c *Categorical) At(i int) color.Color {
+	n := len(c.Colors)
+	return c.Colors[(i%n+n)%n]
+}
+
+// Maps are the continuous maps by name.
+var Maps = map[string]*Map{}
+
+// Sets are the categorical sets by name.
+var Sets = map[string]*Categorical{}
+
+// Names returns the names of the continuous maps in order.
+func Names() []string {
+	names := make([]string, 0, len(Maps))
+	for name := range Maps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func register(m *Map, description string, diverging, safe bool) *Map {
+	m.Description = description
+	m.Diverging = diverging
+	m.ColorblindSafe = safe
+	Maps[m.Name] = m
+	return m
+}
+
+func registerSet(c *Categorical) *Categorical {
+	Sets[c.Name] = c
+	return c
+}
+
+// hex parses colors written as six hexadecimal digits.
+func hex(codes ...string) []color.RGBA {
+	colors := make([]color.RGBA, len(codes))
+	for i, code := range codes {
+		var v [3]uint8
+		for k := range v {
+			v[k] = digit(code[2*k])<<4 | digit(code[2*k+1])
+		}
+		colors[i] = color.RGBA{v[0], v[1], v[2], 0xff}
+	}
+	return colors
+}
+
+func digit(c byte) uint8 {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10
+	}
+	panic("colormap: bad hex digit " + string(c))
+}
+
+var (
+	Grays = register(New("grays", hex("000000", "ffffff")...),
+		"black to white", false, true)
+	Viridis = register(New("viridis", hex(
+		"440154", "472d7b", "3b528b", "2c728e", "21908c",
+		"27ad81", "5dc863", "aadc32", "fde725")...),
+		"perceptual, blue to green to yellow", false, true)
+	Magma = register(New("magma", hex(
+		"000004", "1d1147", "51127c", "822681", "b63679",
+		"e65164", "fb8861", "fec287", "fcfdbf")...),
+		"perceptual, black to purple to cream", false, false)
+	Cividis = register(New("cividis", hex(
+		"00204d", "00336f", "39486b", "575c6d", "707173",
+		"8a8779", "a69d75", "c4b56c", "e4cf5b", "ffea46")...),
+		"perceptual, blue to yellow, made for color vision deficiencies", false, true)
+	Blues = register(New("blues", hex(
+		"f7fbff", "deebf7", "c6dbef", "9ecae1", "6baed6",
+		"4292c6", "2171b5", "08519c", "08306b")...),
+		"white to deep blue, for water", false, true)
+	Terrain = register(New("terrain", hex(
+		"2d6a2e", "4f8a3a", "7fae5a", "c7c47f", "d9b877",
+		"b0814a", "8c6e5b", "a59a94", "ffffff")...),
+		"hypsometric tints from lowland green through brown to snow", false, false)
+	CoolWarm = register(New("coolwarm", hex(
+		"3b4cc0", "8db0fe", "dddddd", "f49a7b", "b40426")...),
+		"diverging, blue to gray to red", true, false)
+	RdBu = register(New("rdbu", hex(
+		"67001f", "b2182b", "d6604d", "f4a582", "fddbc7", "f7f7f7",
+		"d1e5f0", "92c5de", "4393c3", "2166ac", "053061")...),
+		"diverging, red to white to blue", true, true)
+	BrBG = register(New("brbg", hex(
+		"543005", "8c510a", "bf812d", "dfc27d", "f6e8c3", "f5f5f5",
+		"c7eae5", "80cdc1", "35978f", "01665e", "003c30")...),
+		"diverging, brown to white to blue-green", true, true)
+
+	OkabeIto = registerSet(&Categorical{
+		Name:           "okabe-ito",
+		ColorblindSafe: true,
+		Colors: hex("e69f00", "56b4e9", "009e73", "f0e442",
+			"0072b2", "d55e00", "cc79a7", "000000"),
+	})
+	Tableau10 = registerSet(&Categorical{
+		Name: "tableau10",
+		Colors: hex("4e79a7", "f28e2b", "e15759", "76b7b2", "59a14f",
+			"edc948", "b07aa1", "ff9da7", "9c755f", "bab0ac"),
+	})
+)
@@ -0,0 +1,45 @@
+package colormap
+
+import (
+	"image"
+	"image/color"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapEnds(t *testing.T) {
+	for _, m := range Maps {
+		assert.Equal(t, m.stops[0], m.At(-1))
+		assert.Equal(t, m.stops[0], m.At(0))
+		assert.Equal(t, m.stops[len(m.stops)-1], m.At(1))
+		assert.Equal(t, m.stops[len(m.stops)-1], m.At(2))
+	}
+	assert.Equal(t, color.RGBA{0x80, 0x80, 0x80, 0xff}, Grays.At(0.5))
+}
+
+func TestTicks(t *testing.T) {
+	assert.Equal(t, []float64{0, 20, 40, 60, 80, 100}, Ticks(0, 100, 6))
+	assert.Equal(t, []float64{-1, -0.5, 0, 0.5, 1}, Ticks(-1, 1, 5))
+	assert.Equal(t, "0", strconv.FormatFloat(Ticks(-0.7, 0.6, 3)[0], 'g', 4, 64))
+	assert.Equal(t, []float64{5}, Ticks(5, 5, 4))
+}
+
+func TestLegendLabels(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 200, LegendHeight))
+	Viridis.Legend(img, img.Bounds(), 0, 1000, color.White)
+	// The bar runs from one end of the map to the other.
+	assert.Equal(t, Viridis.At(0), img.At(0, 0))
+	assert.Equal(t, Viridis.At(1), img.At(199, 0))
+	// Something is written under the bar.
+	ink := 0
+	for y := 8; y < LegendHeight; y++ {
+		for x := 0; x < 200; x++ {
+			if img.RGBAAt(x, y) == (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+				ink++
+			}
+		}
+	}
+	assert.True(t, ink > 0)
+}
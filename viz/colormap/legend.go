@@ -0,0 +1,89 @@
+package colormap
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+
+	"github.com/kriskowal/bottle-world/viz/font"
+)
+
+// LegendHeight is the height of a legend with its labels.
+const LegendHeight = 8 + 3 + font.Height + 2
+
+// Legend draws a bar of the map across a rectangle, with ticks labeled with
+// the quantities from lo to hi at round numbers.
+// The labels are drawn in a color, under the bar.
+func (m *Map) Legend(dst draw.Image, r image.Rectangle, lo, hi float64, ink color.Color) {
+	bar := image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+8)
+	for x := bar.Min.X; x < bar.Max.X; x++ {
+		c := m.At(float64(x-bar.Min.X) / float64(bar.Dx()-1))
+		draw.Draw(dst, image.Rect(x, bar.Min.Y, x+1, bar.Max.Y), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+	if hi <= lo {
+		label(dst, r, bar.Min.X, strconv.FormatFloat(lo, 'g', 4, 64), ink)
+		return
+	}
+
+	// Leave room for about one label in every forty pixels.
+	for _, v := range Ticks(lo, hi, r.Dx()/40+1) {
+		x := bar.Min.X + int(math.Round((v-lo)/(hi-lo)*float64(bar.Dx()-1)))
+		draw.Draw(dst, image.Rect(x, bar.Max.Y, x+1, bar.Max.Y+2), image.NewUniform(ink), image.Point{}, draw.Src)
+		label(dst, r, x, strconv.FormatFloat(v, 'g', 4, 64), ink)
+	}
+}
+
+// label draws a label centered under a tick, but within the legend.
+func label(dst draw.Image, r image.Rectangle, x int, text string, ink color.Color) {
+	size := font.Measure(text)
+	x -= size.X / 2
+	if x+size.X > r.Max.X {
+		x = r.Max.X - size.X
+	}
+	if x < r.Min.X {
+		x = r.Min.X
+	}
+	font.Draw(dst, image.Pt(x, r.Min.Y+8+3), text, ink)
+}
+
+// Legend draws a swatch of each color of the set in a row across a
+// rectangle, with a label beside each.
+func (c *Categorical) Legend(dst draw.Image, r image.Rectangle, labels []string, ink color.Color) {
+	x := r.Min.X
+	for i, text := range labels {
+		swatch := image.Rect(x, r.Min.Y, x+font.Height, r.Min.Y+font.Height)
+		draw.Draw(dst, swatch, image.NewUniform(c.At(i)), image.Point{}, draw.Src)
+		x = font.Draw(dst, image.Pt(swatch.Max.X+2, r.Min.Y), text, ink).X + font.Advance
+		if x >= r.Max.X {
+			return
+		}
+	}
+}
+
+// Ticks returns round numbers from lo to hi, about n of them, with steps of
+// one, two or five times a power of ten.
+func Ticks(lo, hi float64, n int) []float64 {
+	if n < 2 {
+		n = 2
+	}
+	rough := (hi - lo) / float64(n-1)
+	if rough <= 0 || math.IsNaN(rough) || math.IsInf(rough, 0) {
+		return []float64{lo}
+	}
+	power := math.Pow(10, math.Floor(math.Log10(rough)))
+	step := power * 10
+	for _, m := range []float64{1, 2, 5} {
+		if m*power >= rough {
+			step = m * power
+			break
+		}
+	}
+	var ticks []float64
+	for v := math.Ceil(lo/step) * step; v <= hi+step*1e-9; v += step {
+		// Avoid labels like 0.30000000000000004 and -0.
+		ticks = append(ticks, math.Round(v/step)*step+0)
+	}
+	return ticks
+}
@@ -0,0 +1,72 @@
+package viz
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/kriskowal/bottle-world/viz/font"
+)
+
+// Panel is a frame in a grid, under its title.
+type Panel struct {
+	Title string
+	Image image.Image
+}
+
+// Grid lays out panels in rows, like a dashboard.
+type Grid struct {
+	// Columns is the number of panels in each row, or as many as make the
+	// grid square if zero.
+	Columns int
+}
+
+func (g Grid) columns(n int) int {
+	if g.Columns > 0 {
+		return g.Columns
+	}
+	return int(math.Ceil(math.Sqrt(float64(n))))
+}
+
+// Compose draws panels over black in a grid, in rows from the top left, each
+// centered under its title.
+// Every cell of the grid is as large as the largest panel.
+func (g Grid) Compose(panels ...Panel) *image.RGBA {
+	const gap = 2 * margin
+	titled := false
+	var size image.Point
+	for _, p := range panels {
+		s := p.Image.Bounds().Size()
+		if s.X > size.X {
+			size.X = s.X
+		}
+		if s.Y > size.Y {
+			size.Y = s.Y
+		}
+		titled = titled || p.Title != ""
+	}
+	title := 0
+	if titled {
+		title = font.Height + gap
+	}
+	cell := image.Pt(size.X+gap, title+size.Y+gap)
+
+	columns := g.columns(len(panels))
+	rows := (len(panels) + columns - 1) / columns
+	if len(panels) < columns {
+		columns = len(panels)
+	}
+	out := image.NewRGBA(image.Rect(0, 0, columns*cell.X+gap, rows*cell.Y+gap))
+	draw.Draw(out, out.Bounds(), image.NewUniform(outline), image.Point{}, draw.Src)
+	for i, p := range panels {
+		at := image.Pt(gap+i%columns*cell.X, gap+i/columns*cell.Y)
+		if p.Title != "" {
+			width := font.Measure(p.Title).X
+			font.Draw(out, at.Add(image.Pt((size.X-width)/2, 0)), p.Title, ink)
+		}
+		b := p.Image.Bounds()
+		at = at.Add(image.Pt((size.X-b.Dx())/2, title+(size.Y-b.Dy())/2))
+		draw.Draw(out, b.Sub(b.Min).Add(at), p.Image, b.Min, draw.Src)
+	}
+	return out
+}
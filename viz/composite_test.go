@@ -0,0 +1,62 @@
+package viz
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/kriskowal/bottle-world/viz/font"
+	"github.com/stretchr/testify/assert"
+)
+
+func filled(width, height int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestGridCompose(t *testing.T) {
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	green := color.RGBA{0, 0xff, 0, 0xff}
+	blue := color.RGBA{0, 0, 0xff, 0xff}
+	out := Grid{Columns: 2}.Compose(
+		Panel{Title: "a", Image: filled(10, 6, red)},
+		Panel{Title: "b", Image: filled(6, 4, green)},
+		Panel{Image: filled(10, 6, blue)},
+	)
+	// Every cell of the grid is as large as the largest panel, with room
+	// for a title above and a gutter around.
+	const gap = 2 * margin
+	title := font.Height + gap
+	cell := image.Pt(10+gap, title+6+gap)
+	assert.Equal(t, image.Rect(0, 0, 2*cell.X+gap, 2*cell.Y+gap), out.Bounds())
+
+	at := func(x, y int) color.RGBA { return out.RGBAAt(x, y) }
+	assert.Equal(t, outline, color.Color(at(gap-1, gap+title)))
+	assert.Equal(t, red, at(gap, gap+title))
+	assert.Equal(t, red, at(gap+9, gap+title+5))
+	assert.Equal(t, outline, color.Color(at(gap+10, gap+title)))
+	// Smaller panels are centered in their cells.
+	x, y := cell.X+gap+2, gap+title+1
+	assert.Equal(t, outline, color.Color(at(x-1, y)))
+	assert.Equal(t, green, at(x, y))
+	assert.Equal(t, green, at(x+5, y+3))
+	assert.Equal(t, outline, color.Color(at(x+6, y)))
+	assert.Equal(t, blue, at(gap, cell.Y+gap+title))
+
+	// Titles are drawn over their panels, and only where there are titles.
+	inked := func(x0, y0 int) bool {
+		for y := y0; y < y0+font.Height; y++ {
+			for x := x0; x < x0+10; x++ {
+				if at(x, y) == ink {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	assert.True(t, inked(gap, gap))
+	assert.True(t, inked(cell.X+gap, gap))
+	assert.False(t, inked(gap, cell.Y+gap))
+}
@@ -0,0 +1,197 @@
+package viz
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/kriskowal/bottle-world/sim"
+)
+
+// Quiver draws arrows along the flow of water on a grid of cells, as long as
+// the space between them for the most rapid water of the world.
+type Quiver struct {
+	// Spacing is the number of cells between arrows, 8 if zero.
+	Spacing int
+	// Color is the color of the arrows, white if nil.
+	Color color.Color
+}
+
+func (q Quiver) Draw(dst draw.Image, s Scene) {
+	spacing := q.Spacing
+	if spacing <= 0 {
+		spacing = 8
+	}
+	c := q.Color
+	if c == nil {
+		c = ink
+	}
+	w := s.World
+	fastest := fastestFlow(w)
+	if fastest == 0 {
+		return
+	}
+	zoom := s.View.zoom()
+	longest := float64(spacing) * zoom * 0.9
+	cells := s.View.Cells(w)
+	for y := cells.Min.Y + spacing/2; y < cells.Max.Y; y += spacing {
+		for x := cells.Min.X + spacing/2; x < cells.Max.X; x += spacing {
+			cell := w.Field[wrap(x+s.View.Origin.X, w.Width)][wrap(y+s.View.Origin.Y, w.Height)]
+			dx, dy := float64(cell.WaterDX), float64(cell.WaterDY)
+			speed := math.Hypot(dx, dy)
+			if speed == 0 {
+				continue
+			}
+			length := longest * speed / fastest
+			ux, uy := dx/speed, dy/speed
+			cx := (float64(x-cells.Min.X) + 0.5) * zoom
+			cy := (float64(y-cells.Min.Y) + 0.5) * zoom
+			x0, y0 := cx-ux*length/2, cy-uy*length/2
+			x1, y1 := cx+ux*length/2, cy+uy*length/2
+			line(dst, x0, y0, x1, y1, c)
+			// The barbs of the head sweep back from the tip.
+			barb := math.Max(2, length/3)
+			for _, turn := range []float64{-1, 1} {
+				a := math.Atan2(uy, ux) + math.Pi + turn*math.Pi/6
+				line(dst, x1, y1, x1+barb*math.Cos(a), y1+barb*math.Sin(a), c)
+			}
+		}
+	}
+}
+
+// Streamlines draws lines that follow the flow of water downstream from
+// seeds on a grid of cells, fading in toward their ends so that they show
+// which way the water goes.
+type Streamlines struct {
+	// Spacing is the number of cells between seeds, 16 if zero.
+	Spacing int
+	// Length is the greatest length of a line in cells, twice the spacing
+	// if zero.
+	Length int
+	// Color is the color of the lines, white if nil.
+	Color color.Color
+}
+
+func (l Streamlines) Draw(dst draw.Image, s Scene) {
+	spacing := l.Spacing
+	if spacing <= 0 {
+		spacing = 16
+	}
+	length := l.Length
+	if length <= 0 {
+		length = 2 * spacing
+	}
+	c := l.Color
+	if c == nil {
+		c = ink
+	}
+	r, g, b, _ := c.RGBA()
+	w := s.World
+	fastest := fastestFlow(w)
+	if fastest == 0 {
+		return
+	}
+	flow := flowField(w)
+	zoom := s.View.zoom()
+	cells := s.View.Cells(w)
+	origin := s.View.Origin
+	const step = 0.5
+	steps := int(float64(length) / step)
+	for y := cells.Min.Y + spacing/2; y < cells.Max.Y; y += spacing {
+		for x := cells.Min.X + spacing/2; x < cells.Max.X; x += spacing {
+			// Positions are in cells of the view, from its origin.
+			px, py := float64(x)+0.5, float64(y)+0.5
+			for i := 0; i < steps; i++ {
+				// The midpoint method.
+				dx, dy := flow(px+float64(origin.X), py+float64(origin.Y))
+				speed := math.Hypot(dx, dy)
+				if speed < fastest/100 {
+					break
+				}
+				mx, my := px+dx/speed*step/2, py+dy/speed*step/2
+				dx, dy = flow(mx+float64(origin.X), my+float64(origin.Y))
+				speed = math.Hypot(dx, dy)
+				if speed == 0 {
+					break
+				}
+				nx, ny := px+dx/speed*step, py+dy/speed*step
+				alpha := float64(i+1) / float64(steps)
+				faded := color.RGBA64{
+					uint16(float64(r) * alpha),
+					uint16(float64(g) * alpha),
+					uint16(float64(b) * alpha),
+					uint16(0xffff * alpha),
+				}
+				line(dst,
+					(px-float64(cells.Min.X))*zoom, (py-float64(cells.Min.Y))*zoom,
+					(nx-float64(cells.Min.X))*zoom, (ny-float64(cells.Min.Y))*zoom,
+					faded)
+				px, py = nx, ny
+			}
+		}
+	}
+}
+
+// fastestFlow returns the greatest speed of the flow of water through the
+// cells of a world.
+func fastestFlow(w *sim.World) float64 {
+	fastest := 0.0
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			c := &w.Field[x][y]
+			fastest = math.Max(fastest, math.Hypot(float64(c.WaterDX), float64(c.WaterDY)))
+		}
+	}
+	return fastest
+}
+
+// flowField returns a function that blends the flow of water through the
+// cells of a world nearest to any position on the torus, where the center of
+// a cell is half a cell from its corner.
+func flowField(w *sim.World) func(x, y float64) (dx, dy float64) {
+	return func(x, y float64) (float64, float64) {
+		fx, fy := x-0.5, y-0.5
+		x0, y0 := int(math.Floor(fx)), int(math.Floor(fy))
+		tx, ty := fx-float64(x0), fy-float64(y0)
+		var dx, dy float64
+		for _, corner := range []struct {
+			x, y int
+			t    float64
+		}{
+			{x0, y0, (1 - tx) * (1 - ty)},
+			{x0 + 1, y0, tx * (1 - ty)},
+			{x0, y0 + 1, (1 - tx) * ty},
+			{x0 + 1, y0 + 1, tx * ty},
+		} {
+			c := &w.Field[wrap(corner.x, w.Width)][wrap(corner.y, w.Height)]
+			dx += float64(c.WaterDX) * corner.t
+			dy += float64(c.WaterDY) * corner.t
+		}
+		return dx, dy
+	}
+}
+
+func wrap(n, size int) int {
+	return (n%size + size) % size
+}
+
+// line draws a line between two points, blending its color over the pixels
+// beneath it.
+func line(dst draw.Image, x0, y0, x1, y1 float64, c color.Color) {
+	src := image.NewUniform(c)
+	n := int(math.Ceil(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))))
+	last := image.Pt(math.MinInt32, math.MinInt32)
+	for i := 0; i <= n; i++ {
+		t := 0.0
+		if n > 0 {
+			t = float64(i) / float64(n)
+		}
+		p := image.Pt(int(math.Floor(x0+(x1-x0)*t)), int(math.Floor(y0+(y1-y0)*t)))
+		if p == last {
+			continue
+		}
+		last = p
+		draw.Draw(dst, image.Rectangle{p, p.Add(image.Pt(1, 1))}, src, image.Point{}, draw.Over)
+	}
+}
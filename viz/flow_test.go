@@ -0,0 +1,108 @@
+package viz
+
+import (
+	"image"
+	"testing"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/stretchr/testify/assert"
+)
+
+// flowing returns a world where water flows the same way through every
+// cell.
+func flowing(width, height, dx, dy int) *sim.World {
+	w := &sim.World{Width: width, Height: height, Field: sim.NewField(width, height)}
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			w.Field[x][y].WaterDX = dx
+			w.Field[x][y].WaterDY = dy
+		}
+	}
+	return w
+}
+
+// drawn returns a transparent image of a scene, drawn over by an overlay.
+func drawn(o Overlay, s Scene) *image.RGBA {
+	img := image.NewRGBA(s.View.Bounds(s.World))
+	o.Draw(img, s)
+	return img
+}
+
+func TestQuiverPointsDownstream(t *testing.T) {
+	// The barbs of an arrow are the ink off the line of its shaft, and
+	// they gather at its head.
+	barbs := func(img *image.RGBA, shaft int) (west, east int) {
+		b := img.Bounds()
+		for x := b.Min.X; x < b.Max.X; x++ {
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				if y == shaft || img.RGBAAt(x, y).A == 0 {
+					continue
+				}
+				if x < b.Max.X/2 {
+					west++
+				} else {
+					east++
+				}
+			}
+		}
+		return
+	}
+	view := Viewport{Zoom: 4, Tiles: 1}
+	// A single arrow, centered on the cell at (4, 4), 18 pixels down.
+	west, east := barbs(drawn(Quiver{}, Scene{World: flowing(8, 8, 3, 0), View: view}), 18)
+	assert.Equal(t, 0, west)
+	assert.True(t, east > 0)
+	west, east = barbs(drawn(Quiver{}, Scene{World: flowing(8, 8, -3, 0), View: view}), 18)
+	assert.True(t, west > 0)
+	assert.Equal(t, 0, east)
+}
+
+func TestStreamlinesWrap(t *testing.T) {
+	// Water flows east, but turns south at the western edge of the torus.
+	w := flowing(8, 8, 1, 0)
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < 2; x++ {
+			w.Field[x][y].WaterDX = 0
+			w.Field[x][y].WaterDY = 1
+		}
+	}
+	// Two tiles across show the seam, and one seed shows west of it.
+	img := drawn(Streamlines{Spacing: 12, Length: 8}, Scene{World: w, View: Viewport{Tiles: 2}})
+	inked := func(x0, y0, x1, y1 int) bool {
+		for x := x0; x < x1; x++ {
+			for y := y0; y < y1; y++ {
+				if img.RGBAAt(x, y).A > 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	// The line runs east from its seed, across the seam, and south along
+	// the western edge, as it finds it in the next tile.
+	assert.True(t, inked(6, 6, 8, 7))
+	assert.True(t, inked(8, 10, 10, 16))
+	assert.False(t, inked(11, 0, 16, 16))
+	assert.False(t, inked(0, 0, 6, 16))
+	// The line fades in toward its end.
+	assert.True(t, img.RGBAAt(6, 6).A < img.RGBAAt(8, 11).A)
+}
+
+func TestTrailsFade(t *testing.T) {
+	tracers := &sim.Tracers{}
+	tracers.SeedPoint(1.5, 2.5)
+	tr := tracers.Tracers[0]
+	for x := 2.5; x < 11; x++ {
+		tr.Trajectory = append(tr.Trajectory, [2]float64{x, 2.5})
+	}
+	img := drawn(Trails{Length: 5}, Scene{World: flowing(16, 8, 0, 0), View: Viewport{Tiles: 1}, Tracers: tracers})
+	alpha := func(x int) uint8 { return img.RGBAAt(x, 2).A }
+	// Only the last five positions show, from the cell at 6 to the head at
+	// 10, growing more opaque toward the head.
+	assert.Equal(t, uint8(0), alpha(5))
+	assert.True(t, alpha(6) > 0)
+	assert.True(t, alpha(6) < alpha(7))
+	assert.True(t, alpha(7) < alpha(8))
+	assert.Equal(t, uint8(0xff), alpha(10))
+	assert.Equal(t, uint8(0), alpha(12))
+}
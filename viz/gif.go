@@ -0,0 +1,202 @@
+package viz
+
+import (
+	"bufio"
+	"compress/lzw"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+// GIFWriter encodes an animated GIF one frame at a time, flushing each frame
+// as it is written, so that long runs need not hold every frame in memory
+// and a run that stops early still leaves the frames it rendered.
+// The first frame sets the size of the animation.
+// Close writes the trailer that ends the file.
+type GIFWriter struct {
+	// Palettizer converts frames that are not paletted.
+	// If nil, each frame gets a median cut palette of its own.
+	Palettizer *Palettizer
+
+	w      *bufio.Writer
+	bounds image.Rectangle
+	frames int
+	err    error
+}
+
+// NewGIFWriter returns a writer of an animation that loops forever.
+func NewGIFWriter(w io.Writer) *GIFWriter {
+	return &GIFWriter{w: bufio.NewWriter(w)}
+}
+
+// WriteFrame encodes a frame that shows for delay hundredths of a second.
+func (g *GIFWriter) WriteFrame(frame image.Image, delay int) error {
+	if g.err != nil {
+		return g.err
+	}
+	img, ok := frame.(*image.Paletted)
+	if !ok {
+		p := g.Palettizer
+		if p == nil {
+			p = &Palettizer{Quantizer: MedianCut{}}
+		}
+		img = p.Paletted(frame)
+	}
+	if g.frames == 0 {
+		g.bounds = img.Bounds()
+		g.header()
+	}
+	g.frame(img, delay)
+	g.frames++
+	if g.err == nil {
+		g.err = g.w.Flush()
+	}
+	return g.err
+}
+
+// Close ends the animation.
+// It does not close the underlying writer.
+func (g *GIFWriter) Close() error {
+	if g.err == errClosed {
+		return nil
+	}
+	if g.err != nil {
+		return g.err
+	}
+	if g.frames == 0 {
+		g.err = errors.New("viz: gif has no frames")
+		return g.err
+	}
+	g.w.WriteByte(0x3b)
+	if err := g.w.Flush(); err != nil {
+		g.err = err
+		return err
+	}
+	g.err = errClosed
+	return nil
+}
+
+var errClosed = errors.New("viz: write to closed animation")
+
+func (g *GIFWriter) header() {
+	size := g.bounds.Size()
+	if size.X > 0xffff || size.Y > 0xffff {
+		g.err = fmt.Errorf("viz: gif of %dx%d is too large", size.X, size.Y)
+		return
+	}
+	g.w.WriteString("GIF89a")
+	g.uint16(size.X)
+	g.uint16(size.Y)
+	// No global color table, background color 0, square pixels.
+	g.w.Write([]byte{0x00, 0x00, 0x00})
+	// Loop forever.
+	g.w.Write([]byte{0x21, 0xff, 0x0b})
+	g.w.WriteString("NETSCAPE2.0")
+	g.w.Write([]byte{0x03, 0x01, 0x00, 0x00, 0x00})
+}
+
+func (g *GIFWriter) frame(img *image.Paletted, delay int) {
+	if g.err != nil {
+		return
+	}
+	b := img.Bounds()
+	if !b.In(g.bounds) {
+		g.err = fmt.Errorf("viz: gif frame %v is outside the bounds of the first frame %v", b, g.bounds)
+		return
+	}
+	if len(img.Palette) == 0 || len(img.Palette) > 256 {
+		g.err = fmt.Errorf("viz: gif frame has %d colors, must have 1 to 256", len(img.Palette))
+		return
+	}
+	depth := 1
+	for 1<<depth < len(img.Palette) {
+		depth++
+	}
+
+	// Graphic control extension for the delay.
+	g.w.Write([]byte{0x21, 0xf9, 0x04, 0x00})
+	g.uint16(delay)
+	g.w.Write([]byte{0x00, 0x00})
+
+	// Image descriptor with a local color table.
+	g.w.WriteByte(0x2c)
+	g.uint16(b.Min.X - g.bounds.Min.X)
+	g.uint16(b.Min.Y - g.bounds.Min.Y)
+	g.uint16(b.Dx())
+	g.uint16(b.Dy())
+	g.w.WriteByte(0x80 | byte(depth-1))
+	for i := 0; i < 1<<depth; i++ {
+		if i < len(img.Palette) {
+			r, gr, bl, _ := img.Palette[i].RGBA()
+			g.w.Write([]byte{byte(r >> 8), byte(gr >> 8), byte(bl >> 8)})
+		} else {
+			g.w.Write([]byte{0, 0, 0})
+		}
+	}
+
+	// LZW compressed pixels in blocks of up to 255 bytes.
+	litWidth := depth
+	if litWidth < 2 {
+		litWidth = 2
+	}
+	g.w.WriteByte(byte(litWidth))
+	blocks := &blockWriter{w: g.w}
+	l := lzw.NewWriter(blocks, lzw.LSB, litWidth)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y):][:b.Dx()]
+		for _, p := range row {
+			if int(p) >= len(img.Palette) {
+				g.err = fmt.Errorf("viz: gif frame has color index %d beyond its palette", p)
+				return
+			}
+		}
+		if _, err := l.Write(row); err != nil {
+			g.err = err
+			return
+		}
+	}
+	if err := l.Close(); err != nil {
+		g.err = err
+		return
+	}
+	blocks.close()
+}
+
+func (g *GIFWriter) uint16(n int) {
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], uint16(n))
+	g.w.Write(b[:])
+}
+
+// blockWriter divides data into the length-prefixed sub-blocks of GIF.
+type blockWriter struct {
+	w   *bufio.Writer
+	buf [255]byte
+	n   int
+}
+
+func (b *blockWriter) Write(p []byte) (int, error) {
+	for _, c := range p {
+		b.buf[b.n] = c
+		b.n++
+		if b.n == len(b.buf) {
+			b.flush()
+		}
+	}
+	return len(p), nil
+}
+
+func (b *blockWriter) flush() {
+	if b.n > 0 {
+		b.w.WriteByte(byte(b.n))
+		b.w.Write(b.buf[:b.n])
+		b.n = 0
+	}
+}
+
+func (b *blockWriter) close() {
+	b.flush()
+	b.w.WriteByte(0x00)
+}
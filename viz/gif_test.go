@@ -0,0 +1,44 @@
+package viz
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGIFWriterRoundTrips(t *testing.T) {
+	pal := NewGrayScale()
+	var buf bytes.Buffer
+	g := NewGIFWriter(&buf)
+	var frames []*image.Paletted
+	for n := 0; n < 3; n++ {
+		img := image.NewPaletted(image.Rect(0, 0, 7, 5), pal)
+		for i := range img.Pix {
+			img.Pix[i] = uint8((i*31 + n*17) % len(pal))
+		}
+		frames = append(frames, img)
+		assert.NoError(t, g.WriteFrame(img, n+1))
+	}
+	assert.NoError(t, g.Close())
+
+	decoded, err := gif.DecodeAll(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, decoded.LoopCount)
+	assert.Equal(t, []int{1, 2, 3}, decoded.Delay)
+	assert.Len(t, decoded.Image, 3)
+	for n, img := range decoded.Image {
+		assert.Equal(t, frames[n].Pix, img.Pix)
+	}
+}
+
+func TestGIFWriterRejectsLargerFrames(t *testing.T) {
+	pal := color.Palette{color.Black, color.White}
+	var buf bytes.Buffer
+	g := NewGIFWriter(&buf)
+	assert.NoError(t, g.WriteFrame(image.NewPaletted(image.Rect(0, 0, 2, 2), pal), 0))
+	assert.Error(t, g.WriteFrame(image.NewPaletted(image.Rect(0, 0, 3, 2), pal), 0))
+}
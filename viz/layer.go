@@ -0,0 +1,258 @@
+package viz
+
+import (
+	"image/color"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/kriskowal/bottle-world/viz/colormap"
+)
+
+// Layer is a quantity of each cell that can be rendered.
+type Layer struct {
+	Name        string
+	Description string
+	// Value returns the quantity of a cell.
+	Value func(c *sim.Cell) float64
+	// Range returns the least and greatest quantity in a world, which
+	// render as the ends of the color map.
+	// If nil, the range is found by visiting every cell.
+	Range func(w *sim.World) (lo, hi float64)
+	// ColorMap is the default color map of the layer.
+	ColorMap *colormap.Map
+	// Categories, if any, are the kinds that the values of the layer stand
+	// for, which render in the colors of Set in order instead of through a
+	// color map stretched over a range.
+	Categories []Category
+	Set        *colormap.Categorical
+}
+
+// Category is a kind of the cells of a categorical layer, like a direction,
+// with the value that stands for it.
+type Category struct {
+	Value float64
+	Label string
+}
+
+// directions are the categories of the direction that water flows out of a
+// cell, by the values that stand for them.
+func directions(north, south, west, east, still float64) []Category {
+	return []Category{
+		{north, "north"},
+		{south, "south"},
+		{west, "west"},
+		{east, "east"},
+		{still, "still"},
+	}
+}
+
+// Layers are the layers that can be rendered, by name.
+// Every numeric field of a cell has a layer, named for the field in lower
+// case with words separated by hyphens, like "soil-moisture", in addition
+// to the layers registered with shorter names.
+var Layers = map[string]*Layer{}
+
+// RegisterLayer adds a layer, replacing any layer of the same name.
+func RegisterLayer(l *Layer) {
+	Layers[l.Name] = l
+}
+
+// LayerNames returns the names of the layers in order.
+func LayerNames() []string {
+	names := make([]string, 0, len(Layers))
+	for name := range Layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Categorical reports whether the layer has categories rather than a range.
+func (l *Layer) Categorical() bool {
+	return l.Set != nil
+}
+
+// Labels returns the labels of the categories of the layer, in order.
+func (l *Layer) Labels() []string {
+	labels := make([]string, len(l.Categories))
+	for i, k := range l.Categories {
+		labels[i] = k.Label
+	}
+	return labels
+}
+
+// Extent returns the range of the layer over a world.
+func (l *Layer) Extent(w *sim.World) (lo, hi float64) {
+	if l.Range != nil {
+		return l.Range(w)
+	}
+	lo, hi = math.Inf(1), math.Inf(-1)
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			v := l.Value(&w.Field[x][y])
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+	}
+	return lo, hi
+}
+
+// Render returns a function that colors the cells of a world by the layer,
+// with its color map stretched over the range of the layer in the world.
+func (l *Layer) Render(w *sim.World) func(c *sim.Cell) color.Color {
+	return l.RenderWith(w, l.ColorMap, PerFrame{})
+}
+
+// RenderWith is like Render, but with another color map, stretched over the
+// range that a normalization chooses.
+// A categorical layer renders in the colors of its categories regardless,
+// and cells of no category in black.
+func (l *Layer) RenderWith(w *sim.World, m *colormap.Map, n Normalization) func(c *sim.Cell) color.Color {
+	if l.Categorical() {
+		return func(c *sim.Cell) color.Color {
+			v := l.Value(c)
+			for i, k := range l.Categories {
+				if k.Value == v {
+					return l.Set.At(i)
+				}
+			}
+			return color.Black
+		}
+	}
+	lo, hi := n.Range(l, w)
+	breadth := hi - lo
+	if breadth == 0 {
+		breadth = 1
+	}
+	return func(c *sim.Cell) color.Color {
+		return m.At((l.Value(c) - lo) / breadth)
+	}
+}
+
+func init() {
+	RegisterLayer(&Layer{
+		Name:        "elevation",
+		Description: "elevation of the terrain",
+		Value:       func(c *sim.Cell) float64 { return float64(c.SurfaceElevation) },
+		Range: func(w *sim.World) (float64, float64) {
+			return float64(w.LowestSurfaceElevation), float64(w.HighestSurfaceElevation)
+		},
+		ColorMap: colormap.Terrain,
+	})
+	RegisterLayer(&Layer{
+		Name:        "water",
+		Description: "depth of water over the terrain",
+		Value:       func(c *sim.Cell) float64 { return float64(c.Water) },
+		Range: func(w *sim.World) (float64, float64) {
+			return 0, float64(w.Wettest)
+		},
+		ColorMap: colormap.Blues,
+	})
+	RegisterLayer(&Layer{
+		Name:        "water-elevation",
+		Description: "elevation of the surface of the water",
+		Value:       func(c *sim.Cell) float64 { return float64(c.WaterElevation) },
+		Range: func(w *sim.World) (float64, float64) {
+			return float64(w.LowestWaterElevation), float64(w.HighestWaterElevation)
+		},
+		ColorMap: colormap.Viridis,
+	})
+	RegisterLayer(&Layer{
+		Name:        "heat",
+		Description: "heat of the surface",
+		Value:       func(c *sim.Cell) float64 { return float64(c.SurfaceHeat) },
+		Range: func(w *sim.World) (float64, float64) {
+			return 0, float64(w.HottestSurface)
+		},
+		ColorMap: colormap.Magma,
+	})
+	RegisterLayer(&Layer{
+		Name:        "sunlight",
+		Description: "sunlight falling on the surface",
+		Value:       func(c *sim.Cell) float64 { return float64(c.SunLight) },
+		Range: func(w *sim.World) (float64, float64) {
+			return 0, float64(w.BrightestSurface)
+		},
+		ColorMap: colormap.Cividis,
+	})
+	RegisterLayer(&Layer{
+		Name:        "waterspeed",
+		Description: "speed of the water flowing out of each cell",
+		Value:       func(c *sim.Cell) float64 { return float64(c.WaterSpeed) },
+		Range: func(w *sim.World) (float64, float64) {
+			return 0, float64(w.MostRapidWater)
+		},
+		ColorMap: colormap.Viridis,
+	})
+	RegisterLayer(&Layer{
+		Name:        "water-shed",
+		Description: "direction of the water flowing out of each cell",
+		Value:       func(c *sim.Cell) float64 { return float64(c.WaterShed) },
+		ColorMap:    colormap.Viridis,
+		Categories:  directions(1, 2, 3, 4, 0),
+		Set:         colormap.OkabeIto,
+	})
+
+	// Every other numeric field of a cell, but for its height and width,
+	// which are never set.
+	named := map[string]bool{
+		"Height":           true,
+		"Width":            true,
+		"SurfaceElevation": true,
+		"Water":            true,
+		"WaterElevation":   true,
+		"SurfaceHeat":      true,
+		"SunLight":         true,
+		"WaterSpeed":       true,
+	}
+	fields := reflect.TypeOf(sim.Cell{})
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		switch field.Type.Kind() {
+		case reflect.Int, reflect.Uint8:
+		default:
+			continue
+		}
+		name := hyphenate(field.Name)
+		if named[field.Name] || Layers[name] != nil {
+			continue
+		}
+		index := i
+		RegisterLayer(&Layer{
+			Name:        name,
+			Description: "the " + field.Name + " of each cell",
+			Value: func(c *sim.Cell) float64 {
+				v := reflect.ValueOf(c).Elem().Field(index)
+				if v.Kind() == reflect.Uint8 {
+					return float64(v.Uint())
+				}
+				return float64(v.Int())
+			},
+			ColorMap: colormap.Viridis,
+		})
+	}
+}
+
+// hyphenate turns a name like SoilMoisture into soil-moisture, and WaterDX
+// into water-dx.
+func hyphenate(name string) string {
+	var b strings.Builder
+	lower := false
+	for _, r := range name {
+		if unicode.IsUpper(r) {
+			if lower {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+			lower = false
+		} else {
+			lower = true
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
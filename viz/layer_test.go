@@ -0,0 +1,79 @@
+package viz
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/kriskowal/bottle-world/viz/colormap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayers(t *testing.T) {
+	assert.Equal(t, "soil-moisture", hyphenate("SoilMoisture"))
+	assert.Equal(t, "water-dx", hyphenate("WaterDX"))
+
+	// Give every numeric field of a cell its own value.
+	var c sim.Cell
+	v := reflect.ValueOf(&c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		switch f := v.Field(i); f.Kind() {
+		case reflect.Int:
+			f.SetInt(int64(100 + i))
+		case reflect.Uint8:
+			f.SetUint(uint64(100 + i))
+		}
+	}
+
+	for name, field := range map[string]string{
+		"elevation":       "SurfaceElevation",
+		"water":           "Water",
+		"water-elevation": "WaterElevation",
+		"heat":            "SurfaceHeat",
+		"sunlight":        "SunLight",
+		"waterspeed":      "WaterSpeed",
+		"soil-moisture":   "SoilMoisture",
+		"ground-water":    "GroundWater",
+		"water-table":     "WaterTable",
+		"water-shed":      "WaterShed",
+		"water-dx":        "WaterDX",
+		"water-dy":        "WaterDY",
+	} {
+		layer := Layers[name]
+		if !assert.True(t, layer != nil, name) {
+			continue
+		}
+		assert.Equal(t, name, layer.Name)
+		f, _ := reflect.TypeOf(c).FieldByName(field)
+		assert.Equal(t, float64(100+f.Index[0]), layer.Value(&c), name)
+	}
+
+	// The fields with layers of their own have no second layer.
+	assert.True(t, Layers["surface-elevation"] == nil)
+	assert.True(t, Layers["surface-heat"] == nil)
+	assert.True(t, Layers["height"] == nil)
+	assert.True(t, Layers["width"] == nil)
+	names := LayerNames()
+	for i := 1; i < len(names); i++ {
+		assert.True(t, names[i-1] < names[i])
+	}
+}
+
+func TestCategoricalLayers(t *testing.T) {
+	w := &sim.World{Width: 5, Height: 1, Field: sim.NewField(5, 1)}
+	for x := 0; x < 5; x++ {
+		w.Field[x][0].WaterShed = uint8(x)
+	}
+	for _, name := range []string{"water-shed", "flow-direction"} {
+		layer := Layers[name]
+		assert.True(t, layer.Categorical(), name)
+		assert.Equal(t, []string{"north", "south", "west", "east", "still"}, layer.Labels())
+		getColor := layer.RenderWith(w, colormap.Viridis, PerFrame{})
+		// Water flows north, south, west and east from the cells after
+		// the first, which is still.
+		assert.Equal(t, colormap.OkabeIto.At(4), getColor(&w.Field[0][0]), name)
+		for x := 1; x < 5; x++ {
+			assert.Equal(t, colormap.OkabeIto.At(x-1), getColor(&w.Field[x][0]), name)
+		}
+	}
+}
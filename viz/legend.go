@@ -0,0 +1,37 @@
+package viz
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/kriskowal/bottle-world/viz/colormap"
+	"github.com/kriskowal/bottle-world/viz/font"
+)
+
+// WithLegend returns a frame extended below with a legend of a color map
+// over the range from lo to hi.
+func WithLegend(img image.Image, m *colormap.Map, lo, hi float64) *image.RGBA {
+	out, legend := extendBelow(img, colormap.LegendHeight)
+	m.Legend(out, legend, lo, hi, color.White)
+	return out
+}
+
+// WithCategories returns a frame extended below with a legend of the
+// categories of a layer.
+func WithCategories(img image.Image, l *Layer) *image.RGBA {
+	out, legend := extendBelow(img, font.Height)
+	l.Set.Legend(out, legend, l.Labels(), color.White)
+	return out
+}
+
+// extendBelow returns a frame extended below with room for a legend of some
+// height, and the rectangle of the legend.
+func extendBelow(img image.Image, height int) (*image.RGBA, image.Rectangle) {
+	const pad = 2
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(b.Min.X, b.Min.Y, b.Max.X, b.Max.Y+height+2*pad))
+	draw.Draw(out, out.Bounds(), image.Black, image.Point{}, draw.Src)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+	return out, image.Rect(b.Min.X+pad, b.Max.Y+pad, b.Max.X-pad, b.Max.Y+pad+height)
+}
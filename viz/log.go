@@ -0,0 +1,145 @@
+package viz
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/kriskowal/bottle-world/sim"
+)
+
+// LogFormats are the formats of logs.
+var LogFormats = []string{"csv", "jsonl"}
+
+// LogVersion is the version of the schema of logs, which changes only if
+// the meaning or order of the columns changes.
+// Registering statistics adds columns at the end.
+const LogVersion = 1
+
+// logStats are the statistics that every log records in order, before any
+// others.
+var logStats = []string{
+	"total-water",
+	"total-soil-moisture",
+	"total-ground-water",
+	"highest-surface-elevation",
+	"lowest-surface-elevation",
+	"hottest-surface",
+	"brightest-surface",
+	"wettest",
+	"highest-water-elevation",
+	"lowest-water-elevation",
+	"most-rapid-water",
+	"equatorial-minimum-surface-heat",
+	"equatorial-maximum-surface-heat",
+	"latminheat",
+	"latmaxheat",
+}
+
+// Log writes a record of the statistics of a world after each tick, as
+// comma separated values or JSON Lines.
+// Each record has the tick, the cell under the sun during the tick, the
+// statistics that every log records, and every other statistic in order of
+// its name.
+// A log begins with a line of metadata in JSON, with the version of the
+// schema, the names of the columns, and anything else that describes the
+// run: after "# " for CSV, or as its first object for JSON Lines.
+// CSV then has a header of the names of the columns.
+type Log struct {
+	Stats   []*Stat
+	out     *bufio.Writer
+	csv     *csv.Writer // nil for JSON Lines
+	columns []string
+	row     []string
+}
+
+// NewLog writes the beginning of a log in a format, csv or jsonl, with
+// metadata like the config of the run.
+func NewLog(out io.Writer, format string, meta interface{}) (*Log, error) {
+	l := &Log{out: bufio.NewWriter(out)}
+	logged := map[string]bool{}
+	for _, name := range logStats {
+		l.Stats = append(l.Stats, Stats[name])
+		logged[name] = true
+	}
+	for _, name := range StatNames() {
+		if !logged[name] {
+			l.Stats = append(l.Stats, Stats[name])
+		}
+	}
+	l.columns = l.Columns()
+	header, err := json.Marshal(struct {
+		Version int         `json:"version"`
+		Columns []string    `json:"columns"`
+		Meta    interface{} `json:"meta,omitempty"`
+	}{LogVersion, l.columns, meta})
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "csv":
+		l.out.WriteString("# ")
+		l.out.Write(header)
+		l.out.WriteByte('\n')
+		l.csv = csv.NewWriter(l.out)
+		if err := l.csv.Write(l.columns); err != nil {
+			return nil, err
+		}
+	case "jsonl":
+		l.out.Write(header)
+		l.out.WriteByte('\n')
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+	l.row = make([]string, len(l.columns))
+	return l, nil
+}
+
+// Columns returns the names of the columns of the log.
+func (l *Log) Columns() []string {
+	columns := []string{"tick", "sun-x", "sun-y"}
+	for _, s := range l.Stats {
+		columns = append(columns, s.Name)
+	}
+	return columns
+}
+
+// Record writes the statistics of a world after some ticks.
+func (l *Log) Record(w *sim.World, ticks int) error {
+	x, y := sim.Sun(w.Width, w.Height, ticks-1)
+	l.row[0] = strconv.Itoa(ticks)
+	l.row[1] = strconv.Itoa(x)
+	l.row[2] = strconv.Itoa(y)
+	for i, s := range l.Stats {
+		l.row[3+i] = strconv.FormatFloat(s.Value(w), 'f', -1, 64)
+	}
+	if l.csv != nil {
+		return l.csv.Write(l.row)
+	}
+	// Objects keep the order of the columns, which maps would not.
+	l.out.WriteByte('{')
+	for i, name := range l.columns {
+		if i > 0 {
+			l.out.WriteByte(',')
+		}
+		l.out.WriteString(strconv.Quote(name))
+		l.out.WriteByte(':')
+		l.out.WriteString(l.row[i])
+	}
+	_, err := l.out.WriteString("}\n")
+	return err
+}
+
+// Flush writes any buffered records.
+func (l *Log) Flush() error {
+	if l.csv != nil {
+		l.csv.Flush()
+		if err := l.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return l.out.Flush()
+}
@@ -0,0 +1,54 @@
+package viz
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLog(t *testing.T) {
+	w := &sim.World{Width: 10, Height: 4, HottestSurface: 7, Field: sim.NewField(10, 4)}
+
+	var out bytes.Buffer
+	l, err := NewLog(&out, "csv", map[string]int{"seed": 3})
+	assert.NoError(t, err)
+	assert.NoError(t, l.Record(w, 3))
+	assert.NoError(t, l.Flush())
+	lines := strings.Split(out.String(), "\n")
+	assert.True(t, strings.HasPrefix(lines[0], `# {"version":1,"columns":["tick","sun-x","sun-y",`))
+	assert.True(t, strings.HasSuffix(lines[0], `"meta":{"seed":3}}`))
+	assert.Equal(t, strings.Join(l.Columns(), ","), lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "3,8,2,"))
+
+	out.Reset()
+	l, err = NewLog(&out, "jsonl", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Record(w, 3))
+	assert.NoError(t, l.Flush())
+	lines = strings.Split(out.String(), "\n")
+	var record map[string]float64
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, 7.0, record["hottest-surface"])
+	assert.Equal(t, 8.0, record["sun-x"])
+	assert.True(t, strings.HasPrefix(lines[1], `{"tick":3,"sun-x":8,`))
+}
+
+func TestLogColumns(t *testing.T) {
+	RegisterStat(&Stat{Name: "aardvarks", Value: func(w *sim.World) float64 { return 0 }})
+	defer delete(Stats, "aardvarks")
+	l, err := NewLog(&bytes.Buffer{}, "csv", nil)
+	assert.NoError(t, err)
+	columns := l.Columns()
+	// The columns every log records come first, in a fixed order, and
+	// registered statistics follow.
+	assert.Equal(t, []string{"tick", "sun-x", "sun-y", "total-water", "total-soil-moisture", "total-ground-water", "highest-surface-elevation"}, columns[:7])
+	assert.Equal(t, "aardvarks", columns[3+len(logStats)])
+	for _, name := range logStats {
+		assert.True(t, Stats[name] != nil, name)
+	}
+	assert.Len(t, columns, 3+len(Stats))
+}
@@ -0,0 +1,111 @@
+package viz
+
+import (
+	"math"
+	"sort"
+
+	"github.com/kriskowal/bottle-world/sim"
+)
+
+// Normalization chooses the range of a layer that a color map spans in each
+// frame.
+// Normalizations may learn from the frames they see, so each animation
+// needs its own.
+type Normalization interface {
+	Range(l *Layer, w *sim.World) (lo, hi float64)
+}
+
+// PerFrame spans the range of each frame, which shows the most detail in
+// every frame, but rescales between frames.
+type PerFrame struct{}
+
+// Range implements Normalization.
+func (PerFrame) Range(l *Layer, w *sim.World) (float64, float64) {
+	return l.Extent(w)
+}
+
+// Fixed spans a given range for each layer by name, and the range of each
+// frame for other layers.
+type Fixed map[string][2]float64
+
+// Range implements Normalization.
+func (f Fixed) Range(l *Layer, w *sim.World) (float64, float64) {
+	if r, ok := f[l.Name]; ok {
+		return r[0], r[1]
+	}
+	return l.Extent(w)
+}
+
+// Running spans the range of every frame so far, widening as the run goes.
+type Running struct {
+	ranges map[*Layer][2]float64
+}
+
+// Range implements Normalization.
+func (r *Running) Range(l *Layer, w *sim.World) (float64, float64) {
+	if r.ranges == nil {
+		r.ranges = map[*Layer][2]float64{}
+	}
+	lo, hi := l.Extent(w)
+	if seen, ok := r.ranges[l]; ok {
+		lo, hi = math.Min(lo, seen[0]), math.Max(hi, seen[1])
+	}
+	r.ranges[l] = [2]float64{lo, hi}
+	return lo, hi
+}
+
+// WholeRun spans the range of every frame of the run, which it learns by
+// seeing every frame in a first pass over the run before it is fixed.
+// Until then, it is Running.
+type WholeRun struct {
+	Running
+	fixed bool
+}
+
+// Range implements Normalization.
+func (r *WholeRun) Range(l *Layer, w *sim.World) (float64, float64) {
+	if r.fixed {
+		if seen, ok := r.ranges[l]; ok {
+			return seen[0], seen[1]
+		}
+	}
+	return r.Running.Range(l, w)
+}
+
+// Fix stops learning, so that every later frame has the range of the frames
+// seen so far.
+func (r *WholeRun) Fix() {
+	r.fixed = true
+}
+
+// Percentile spans the range between two percentiles of each frame, so that
+// a few extreme cells do not wash out the rest.
+// Cells beyond the range take the colors at the ends of the color map.
+type Percentile struct {
+	Lo, Hi float64
+}
+
+// Range implements Normalization.
+func (p Percentile) Range(l *Layer, w *sim.World) (float64, float64) {
+	values := make([]float64, 0, w.Width*w.Height)
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			values = append(values, l.Value(&w.Field[x][y]))
+		}
+	}
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sort.Float64s(values)
+	at := func(percent float64) float64 {
+		i := int(math.Round(percent / 100 * float64(len(values)-1)))
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(values) {
+			i = len(values) - 1
+		}
+		return values[i]
+	}
+	return at(p.Lo), at(p.Hi)
+}
@@ -0,0 +1,65 @@
+package viz
+
+import (
+	"testing"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/kriskowal/bottle-world/viz/colormap"
+	"github.com/stretchr/testify/assert"
+)
+
+// moist is a layer of the soil moisture of each cell, without a range of
+// its own.
+var moist = &Layer{
+	Name:     "moist",
+	Value:    func(c *sim.Cell) float64 { return float64(c.SoilMoisture) },
+	ColorMap: colormap.Viridis,
+}
+
+// wetted returns a world of ten cells with moisture from lo up by step.
+func wetted(lo, step int) *sim.World {
+	w := &sim.World{Width: 10, Height: 1, Field: sim.NewField(10, 1)}
+	for x := 0; x < 10; x++ {
+		w.Field[x][0].SoilMoisture = lo + step*x
+	}
+	return w
+}
+
+func TestNormalizations(t *testing.T) {
+	first, second := wetted(10, 1), wetted(0, 2)
+
+	lo, hi := PerFrame{}.Range(moist, second)
+	assert.Equal(t, [2]float64{0, 18}, [2]float64{lo, hi})
+
+	fixed := Fixed{"moist": {5, 50}}
+	lo, hi = fixed.Range(moist, first)
+	assert.Equal(t, [2]float64{5, 50}, [2]float64{lo, hi})
+	lo, hi = fixed.Range(Layers["water"], first)
+	assert.Equal(t, [2]float64{0, 0}, [2]float64{lo, hi})
+
+	running := &Running{}
+	running.Range(moist, first)
+	lo, hi = running.Range(moist, second)
+	assert.Equal(t, [2]float64{0, 19}, [2]float64{lo, hi})
+
+	whole := &WholeRun{}
+	whole.Range(moist, first)
+	whole.Range(moist, second)
+	whole.Fix()
+	// Once fixed, later frames take the range of the whole first pass.
+	lo, hi = whole.Range(moist, wetted(100, 0))
+	assert.Equal(t, [2]float64{0, 19}, [2]float64{lo, hi})
+
+	lo, hi = Percentile{Lo: 10, Hi: 90}.Range(moist, first)
+	assert.Equal(t, [2]float64{11, 18}, [2]float64{lo, hi})
+}
+
+func TestNormalizeConstantLayer(t *testing.T) {
+	w := wetted(7, 0)
+	for _, n := range []Normalization{PerFrame{}, &Running{}, &WholeRun{}, Percentile{Lo: 2, Hi: 98}} {
+		lo, hi := n.Range(moist, w)
+		assert.Equal(t, [2]float64{7, 7}, [2]float64{lo, hi})
+		// A layer without breadth takes the color at the bottom of the map.
+		assert.Equal(t, colormap.Viridis.At(0), moist.RenderWith(w, colormap.Viridis, n)(&w.Field[3][0]))
+	}
+}
@@ -0,0 +1,107 @@
+package viz
+
+import (
+	"errors"
+	"image"
+	"image/gif"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// File is an output file that is written beside its path and renamed into
+// place when it is closed, so that readers of the path see either the old
+// file or the whole new one, never a partial or stale mix of the two.
+type File struct {
+	f      *os.File
+	path   string
+	perm   os.FileMode
+	closed bool
+}
+
+// Create begins writing a file that will have the given permissions.
+func Create(path string, perm os.FileMode) (*File, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		var pe *os.PathError
+		if errors.As(err, &pe) {
+			err = pe.Err
+		}
+		return nil, &os.PathError{Op: "create", Path: path, Err: err}
+	}
+	return &File{f: f, path: path, perm: perm}, nil
+}
+
+// Name returns the path that the file will have once it is closed.
+func (f *File) Name() string {
+	return f.path
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	return f.f.Write(p)
+}
+
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	return f.f.Seek(offset, whence)
+}
+
+// Close finishes the file and moves it into place.
+// If it fails, the path is left as it was.
+func (f *File) Close() error {
+	if f.closed {
+		return errors.New("viz: file already closed")
+	}
+	f.closed = true
+	err := f.f.Sync()
+	if cerr := f.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.f.Name(), f.perm)
+	}
+	if err == nil {
+		err = os.Rename(f.f.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.f.Name())
+	}
+	return err
+}
+
+// Abort discards the file and leaves the path as it was.
+// Abort after Close does nothing.
+func (f *File) Abort() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	f.f.Close()
+	return os.Remove(f.f.Name())
+}
+
+// WriteFile writes a file with the given permissions through a function
+// that encodes its content, replacing the file only if the function and the
+// write both succeed.
+func WriteFile(path string, perm os.FileMode, encode func(io.Writer) error) error {
+	f, err := Create(path, perm)
+	if err != nil {
+		return err
+	}
+	if err := encode(f); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Close()
+}
+
+// EncodeGIF writes images as an animated GIF.
+func EncodeGIF(out io.Writer, images []*image.Paletted, delays []int) error {
+	return gif.EncodeAll(out, &gif.GIF{
+		Image: images,
+		Delay: delays,
+	})
+}
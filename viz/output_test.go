@@ -0,0 +1,44 @@
+package viz
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFileReplacesWholeFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.gif")
+	assert.NoError(t, os.WriteFile(path, []byte("a much longer old file"), 0600))
+
+	assert.NoError(t, WriteFile(path, 0640, func(w io.Writer) error {
+		_, err := w.Write([]byte("new"))
+		return err
+	}))
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+func TestWriteFileKeepsOldFileOnError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.gif")
+	assert.NoError(t, os.WriteFile(path, []byte("old"), 0600))
+
+	failure := errors.New("encode failed")
+	err := WriteFile(path, 0644, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return failure
+	})
+	assert.Equal(t, failure, err)
+	data, _ := os.ReadFile(path)
+	assert.Equal(t, "old", string(data))
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+}
@@ -0,0 +1,245 @@
+package viz
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/kriskowal/bottle-world/viz/font"
+)
+
+// Scene is what overlays know of a frame: the world it shows, how many
+// ticks the world has run, and the view it was captured through.
+type Scene struct {
+	World *sim.World
+	// Tick is the number of ticks the world has run, so the sun last shone
+	// on it at tick Tick-1.
+	Tick int
+	View Viewport
+	// Tracers are the particles drifting with the water, if any.
+	Tracers *sim.Tracers
+	// Series are statistics of the world at every frame so far, if any.
+	Series *Series
+}
+
+// Overlay annotates a frame.
+type Overlay interface {
+	Draw(dst draw.Image, s Scene)
+}
+
+// Annotate draws overlays over a frame in order.
+func Annotate(dst draw.Image, s Scene, overlays ...Overlay) {
+	for _, o := range overlays {
+		o.Draw(dst, s)
+	}
+}
+
+// Corner is a corner of a frame.
+type Corner int
+
+const (
+	TopLeft Corner = iota
+	TopRight
+	BottomLeft
+	BottomRight
+)
+
+// margin is the space between an overlay and the edges of a frame.
+const margin = 2
+
+// place returns the top left of a box of a size in a corner of bounds.
+func (c Corner) place(bounds image.Rectangle, size image.Point) image.Point {
+	at := bounds.Min.Add(image.Pt(margin, margin))
+	if c == TopRight || c == BottomRight {
+		at.X = bounds.Max.X - margin - size.X
+	}
+	if c == BottomLeft || c == BottomRight {
+		at.Y = bounds.Max.Y - margin - size.Y
+	}
+	return at
+}
+
+var (
+	ink     = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	outline = color.RGBA{0, 0, 0, 0xff}
+)
+
+// Text writes text in a corner of a frame.
+// Fields in braces expand to the state of the scene: {tick}, {date}, or
+// the value of any statistic, like {hottest-surface}.
+type Text struct {
+	Text   string
+	Corner Corner
+	// Color is the color of the text, white if nil, outlined in black.
+	Color color.Color
+}
+
+func (t Text) Draw(dst draw.Image, s Scene) {
+	text := Expand(t.Text, s)
+	c := t.Color
+	if c == nil {
+		c = ink
+	}
+	at := t.Corner.place(dst.Bounds(), font.Measure(text))
+	font.Default.DrawOutlined(dst, at, text, c, outline)
+}
+
+var field = regexp.MustCompile(`\{([a-z-]+)\}`)
+
+// Expand replaces the fields of text with the state of a scene, leaving
+// unknown fields as they are.
+func Expand(text string, s Scene) string {
+	return field.ReplaceAllStringFunc(text, func(f string) string {
+		name := f[1 : len(f)-1]
+		switch name {
+		case "tick":
+			return strconv.Itoa(s.Tick)
+		case "date":
+			return Date(s.World, s.Tick)
+		}
+		if stat := Stats[name]; stat != nil {
+			return strconv.FormatFloat(stat.Value(s.World), 'f', -1, 64)
+		}
+		return f
+	})
+}
+
+// TextFields returns the names of the fields that text may expand, in
+// order.
+func TextFields() []string {
+	return append([]string{"date", "tick"}, StatNames()...)
+}
+
+// UnknownFields returns the fields of text that Expand would leave as they
+// are.
+func UnknownFields(text string) []string {
+	var unknown []string
+	for _, m := range field.FindAllStringSubmatch(text, -1) {
+		name := m[1]
+		if name != "tick" && name != "date" && Stats[name] == nil {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// Date returns the day and time of day of a world after some ticks,
+// counting a day for each crossing of the sun, like "day 3 14:24".
+func Date(w *sim.World, ticks int) string {
+	day := ticks / w.Width
+	minutes := ticks % w.Width * 24 * 60 / w.Width
+	return fmt.Sprintf("day %d %02d:%02d", day+1, minutes/60, minutes%60)
+}
+
+// Sun marks the cell under the sun with a ring.
+type Sun struct {
+	// Color is the color of the ring, yellow if nil.
+	Color color.Color
+}
+
+func (u Sun) Draw(dst draw.Image, s Scene) {
+	c := u.Color
+	if c == nil {
+		c = color.RGBA{0xff, 0xd7, 0x00, 0xff}
+	}
+	x, y := sim.Sun(s.World.Width, s.World.Height, s.Tick-1)
+	for _, p := range s.View.Pixels(s.World, image.Pt(x, y)) {
+		ring(dst, p, 4, outline)
+		ring(dst, p, 3, c)
+	}
+}
+
+// Probe marks a cell with a cross and a label, and the value of a layer in
+// that cell if it names one.
+type Probe struct {
+	Label string
+	Cell  image.Point
+	// Layer is the name of the layer to show, if any.
+	Layer string
+	// Color is the color of the cross and label, white if nil.
+	Color color.Color
+}
+
+func (p Probe) Draw(dst draw.Image, s Scene) {
+	c := p.Color
+	if c == nil {
+		c = ink
+	}
+	label := p.Label
+	if layer := Layers[p.Layer]; layer != nil {
+		cell := &s.World.Field[wrap(p.Cell.X, s.World.Width)][wrap(p.Cell.Y, s.World.Height)]
+		value := strconv.FormatFloat(layer.Value(cell), 'f', -1, 64)
+		if label != "" {
+			label += " "
+		}
+		label += value
+	}
+	for _, at := range s.View.Pixels(s.World, p.Cell) {
+		cross(dst, at, 3, c)
+		if label != "" {
+			font.Default.DrawOutlined(dst, at.Add(image.Pt(5, -font.Height/2)), label, c, outline)
+		}
+	}
+}
+
+// ScaleBar draws a bar as long as some cells, labeled with its length.
+type ScaleBar struct {
+	Cells  int
+	Corner Corner
+	// Unit and PerCell label the bar with a length in some unit, like
+	// "km", instead of cells.
+	Unit    string
+	PerCell float64
+}
+
+func (b ScaleBar) Draw(dst draw.Image, s Scene) {
+	length := int(float64(b.Cells) * s.View.zoom())
+	label := fmt.Sprintf("%d cells", b.Cells)
+	if b.Unit != "" {
+		label = strconv.FormatFloat(float64(b.Cells)*b.PerCell, 'f', -1, 64) + " " + b.Unit
+	}
+	text := font.Measure(label)
+	const bar = 3
+	size := image.Pt(length, text.Y+2+bar)
+	if text.X > size.X {
+		size.X = text.X
+	}
+	at := b.Corner.place(dst.Bounds(), size)
+	font.Default.DrawOutlined(dst, at, label, ink, outline)
+	r := image.Rect(at.X, at.Y+text.Y+2, at.X+length, at.Y+text.Y+2+bar)
+	draw.Draw(dst, r.Inset(-1), image.NewUniform(outline), image.Point{}, draw.Src)
+	draw.Draw(dst, r, image.NewUniform(ink), image.Point{}, draw.Src)
+}
+
+// ring draws a circle of some radius about a point.
+func ring(dst draw.Image, at image.Point, r int, c color.Color) {
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			d := dx*dx + dy*dy
+			if d <= r*r && d > (r-1)*(r-1) {
+				dst.Set(at.X+dx, at.Y+dy, c)
+			}
+		}
+	}
+}
+
+// cross draws a cross of some radius about a point, outlined in black.
+func cross(dst draw.Image, at image.Point, r int, c color.Color) {
+	for _, k := range []struct {
+		c color.Color
+		w int
+	}{{outline, 1}, {c, 0}} {
+		for d := -r - k.w; d <= r+k.w; d++ {
+			for t := -k.w; t <= k.w; t++ {
+				dst.Set(at.X+d, at.Y+t, k.c)
+				dst.Set(at.X+t, at.Y+d, k.c)
+			}
+		}
+	}
+}
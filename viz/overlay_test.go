@@ -0,0 +1,31 @@
+package viz
+
+import (
+	"image"
+	"testing"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPixels(t *testing.T) {
+	w := &sim.World{Width: 8, Height: 4}
+
+	// The default view shows a quarter again of the torus, so cells near
+	// the origin show twice across and down.
+	assert.Equal(t, []image.Point{{1, 2}, {9, 2}}, Viewport{}.Pixels(w, image.Pt(1, 2)))
+	assert.Equal(t, []image.Point{{5, 2}}, Viewport{}.Pixels(w, image.Pt(5, 2)))
+	assert.Equal(t, []image.Point{{1, 0}, {9, 0}, {1, 4}, {9, 4}}, Viewport{}.Pixels(w, image.Pt(1, 0)))
+
+	v := Viewport{Origin: image.Pt(6, 1), Zoom: 2, Crop: image.Rect(-2, 0, 4, 3)}
+	assert.Equal(t, []image.Point{{3, 1}}, v.Pixels(w, image.Pt(5, 1)))
+	assert.Equal(t, []image.Point{{7, 1}}, v.Pixels(w, image.Pt(7, 1)))
+	assert.Empty(t, v.Pixels(w, image.Pt(2, 1)))
+}
+
+func TestExpand(t *testing.T) {
+	w := &sim.World{Width: 100, Height: 50, HottestSurface: 42}
+	s := Scene{World: w, Tick: 275}
+	assert.Equal(t, "tick 275, day 3 18:00, 42 {nonsense}", Expand("tick {tick}, {date}, {hottest-surface} {nonsense}", s))
+	assert.Equal(t, []string{"nonsense"}, UnknownFields("{tick} {nonsense}"))
+}
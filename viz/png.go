@@ -0,0 +1,262 @@
+package viz
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+)
+
+// PNGSequence writes each frame to its own true-color PNG file, named by
+// formatting the frame number into a pattern like "frames/heat-%05d.png".
+// Delays are not recorded.
+type PNGSequence struct {
+	Pattern string
+	Perm    os.FileMode
+	frames  int
+}
+
+// NewPNGSequence returns a sequence of frames named by a pattern.
+func NewPNGSequence(pattern string, perm os.FileMode) *PNGSequence {
+	return &PNGSequence{Pattern: pattern, Perm: perm}
+}
+
+// WriteFrame writes the next frame of the sequence.
+func (s *PNGSequence) WriteFrame(img image.Image, delay int) error {
+	file := fmt.Sprintf(s.Pattern, s.frames)
+	s.frames++
+	return WriteFile(file, s.Perm, func(w io.Writer) error {
+		return png.Encode(w, img)
+	})
+}
+
+// Close ends the sequence.
+func (s *PNGSequence) Close() error {
+	return nil
+}
+
+// APNGWriter encodes an animated PNG one frame at a time.
+// Every frame is stored as 8-bit RGBA, so frames may have any colors.
+// The first frame sets the size of the animation and is also the image that
+// viewers without animation show.
+// The count of frames is patched into the header when the writer closes,
+// so the underlying writer must be able to seek.
+type APNGWriter struct {
+	ws       io.WriteSeeker
+	w        *bufio.Writer
+	bounds   image.Rectangle
+	frames   int
+	sequence uint32
+	err      error
+}
+
+// NewAPNGWriter returns a writer of an animation that loops forever.
+func NewAPNGWriter(w io.WriteSeeker) *APNGWriter {
+	return &APNGWriter{ws: w, w: bufio.NewWriter(w)}
+}
+
+// acTLOffset is the offset of the animation control chunk, after the
+// signature and the header chunk.
+const acTLOffset = 8 + 12 + 13
+
+// WriteFrame encodes a frame that shows for delay hundredths of a second.
+func (a *APNGWriter) WriteFrame(img image.Image, delay int) error {
+	if a.err != nil {
+		return a.err
+	}
+	b := img.Bounds()
+	if a.frames == 0 {
+		a.bounds = b
+		a.header()
+	} else if !b.In(a.bounds) {
+		a.err = fmt.Errorf("viz: png frame %v is outside the bounds of the first frame %v", b, a.bounds)
+		return a.err
+	}
+
+	var fctl [26]byte
+	binary.BigEndian.PutUint32(fctl[0:], a.next())
+	binary.BigEndian.PutUint32(fctl[4:], uint32(b.Dx()))
+	binary.BigEndian.PutUint32(fctl[8:], uint32(b.Dy()))
+	binary.BigEndian.PutUint32(fctl[12:], uint32(b.Min.X-a.bounds.Min.X))
+	binary.BigEndian.PutUint32(fctl[16:], uint32(b.Min.Y-a.bounds.Min.Y))
+	binary.BigEndian.PutUint16(fctl[20:], uint16(delay))
+	binary.BigEndian.PutUint16(fctl[22:], 100)
+	// fctl[24:26] leave the frame in place and replace what it covers.
+	a.chunk("fcTL", fctl[:])
+
+	data := &dataWriter{a: a}
+	if a.frames > 0 {
+		data.kind = "fdAT"
+	} else {
+		data.kind = "IDAT"
+	}
+	z, _ := zlib.NewWriterLevel(data, zlib.BestSpeed)
+	if err := writePixels(z, img); err != nil && a.err == nil {
+		a.err = err
+	}
+	if err := z.Close(); err != nil && a.err == nil {
+		a.err = err
+	}
+	data.flush()
+	a.frames++
+	if a.err == nil {
+		a.err = a.w.Flush()
+	}
+	return a.err
+}
+
+// Close ends the animation and records its count of frames.
+// It does not close the underlying writer.
+func (a *APNGWriter) Close() error {
+	if a.err == errClosed {
+		return nil
+	}
+	if a.err != nil {
+		return a.err
+	}
+	if a.frames == 0 {
+		a.err = errors.New("viz: png has no frames")
+		return a.err
+	}
+	a.chunk("IEND", nil)
+	if a.err == nil {
+		a.err = a.w.Flush()
+	}
+	if a.err != nil {
+		return a.err
+	}
+
+	end, err := a.ws.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = a.ws.Seek(acTLOffset, io.SeekStart)
+	}
+	if err == nil {
+		a.actl()
+		err = a.w.Flush()
+	}
+	if err == nil {
+		_, err = a.ws.Seek(end, io.SeekStart)
+	}
+	if err != nil {
+		a.err = err
+		return err
+	}
+	a.err = errClosed
+	return nil
+}
+
+func (a *APNGWriter) header() {
+	a.w.WriteString("\x89PNG\r\n\x1a\n")
+	var ihdr [13]byte
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(a.bounds.Dx()))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(a.bounds.Dy()))
+	ihdr[8] = 8  // bits per sample
+	ihdr[9] = 6  // RGBA
+	ihdr[10] = 0 // deflate
+	ihdr[11] = 0 // adaptive filtering
+	ihdr[12] = 0 // not interlaced
+	a.chunk("IHDR", ihdr[:])
+	a.actl()
+}
+
+// actl writes the animation control chunk with the frames so far.
+func (a *APNGWriter) actl() {
+	var actl [8]byte
+	binary.BigEndian.PutUint32(actl[0:], uint32(a.frames))
+	// actl[4:8] loop forever.
+	a.chunk("acTL", actl[:])
+}
+
+func (a *APNGWriter) next() uint32 {
+	n := a.sequence
+	a.sequence++
+	return n
+}
+
+func (a *APNGWriter) chunk(kind string, data []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(data)))
+	a.w.Write(n[:])
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(kind))
+	crc.Write(data)
+	a.w.WriteString(kind)
+	a.w.Write(data)
+	binary.BigEndian.PutUint32(n[:], crc.Sum32())
+	a.w.Write(n[:])
+}
+
+// dataWriter divides the compressed pixels of a frame into chunks.
+// Chunks of frames after the first begin with a sequence number.
+type dataWriter struct {
+	a    *APNGWriter
+	kind string
+	buf  []byte
+}
+
+const dataChunkSize = 1 << 16
+
+func (d *dataWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if d.buf == nil {
+			d.buf = make([]byte, 4, 4+dataChunkSize)
+		}
+		room := cap(d.buf) - len(d.buf)
+		if room > len(p) {
+			room = len(p)
+		}
+		d.buf = append(d.buf, p[:room]...)
+		p = p[room:]
+		if len(d.buf) == cap(d.buf) {
+			d.flush()
+		}
+	}
+	return n, nil
+}
+
+func (d *dataWriter) flush() {
+	if len(d.buf) <= 4 {
+		return
+	}
+	if d.kind == "fdAT" {
+		binary.BigEndian.PutUint32(d.buf, d.a.next())
+		d.a.chunk(d.kind, d.buf)
+	} else {
+		d.a.chunk(d.kind, d.buf[4:])
+	}
+	d.buf = d.buf[:4]
+}
+
+// writePixels writes the rows of an image as 8-bit RGBA, each row filtered
+// by its difference from the row above.
+func writePixels(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	stride := b.Dx() * 4
+	prev := make([]byte, stride)
+	row := make([]byte, stride)
+	line := make([]byte, 1+stride)
+	line[0] = 2 // up
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			i := (x - b.Min.X) * 4
+			row[i], row[i+1], row[i+2], row[i+3] = c.R, c.G, c.B, c.A
+		}
+		for i := range row {
+			line[1+i] = row[i] - prev[i]
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		prev, row = row, prev
+	}
+	return nil
+}
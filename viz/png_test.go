@@ -0,0 +1,73 @@
+package viz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPNGWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.png")
+	f, err := Create(path, 0644)
+	assert.NoError(t, err)
+	a := NewAPNGWriter(f)
+	var first *image.RGBA
+	for n := 0; n < 3; n++ {
+		img := image.NewRGBA(image.Rect(0, 0, 300, 200))
+		for y := 0; y < 200; y++ {
+			for x := 0; x < 300; x++ {
+				img.Set(x, y, color.RGBA{uint8(x + n), uint8(y * n), uint8(x ^ y), 0xff})
+			}
+		}
+		if first == nil {
+			first = img
+		}
+		assert.NoError(t, a.WriteFrame(img, 5))
+	}
+	assert.NoError(t, a.Close())
+	assert.NoError(t, f.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	// Viewers without animation see the first frame.
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	same := true
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			same = same && color.RGBAModel.Convert(img.At(x, y)) == first.At(x, y)
+		}
+	}
+	assert.True(t, same)
+
+	chunks := map[string]int{}
+	frames := -1
+	var sequence []uint32
+	for i := 8; i < len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		kind := string(data[i+4 : i+8])
+		body := data[i+8 : i+8+n]
+		chunks[kind]++
+		switch kind {
+		case "acTL":
+			frames = int(binary.BigEndian.Uint32(body))
+		case "fcTL", "fdAT":
+			sequence = append(sequence, binary.BigEndian.Uint32(body))
+		}
+		i += 12 + n
+	}
+	assert.Equal(t, 3, frames)
+	assert.Equal(t, 3, chunks["fcTL"])
+	assert.Equal(t, 1, chunks["IEND"])
+	for i, s := range sequence {
+		assert.Equal(t, uint32(i), s)
+	}
+}
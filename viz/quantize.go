@@ -0,0 +1,349 @@
+package viz
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// Histogram counts the pixels of each color in one or more images.
+type Histogram map[color.RGBA]int
+
+// Add counts the pixels of an image.
+func (h Histogram) Add(img image.Image) {
+	b := img.Bounds()
+	if rgba, ok := img.(*image.RGBA); ok {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			row := rgba.Pix[rgba.PixOffset(b.Min.X, y):][:4*b.Dx()]
+			for i := 0; i < len(row); i += 4 {
+				h[color.RGBA{row[i], row[i+1], row[i+2], row[i+3]}]++
+			}
+		}
+		return
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			h[color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)]++
+		}
+	}
+}
+
+// Quantizer chooses a palette of at most n colors that best stands for the
+// colors of a histogram.
+type Quantizer interface {
+	Palette(h Histogram, n int) color.Palette
+}
+
+// MedianCut quantizes colors by dividing the box that bounds them, again and
+// again, at the median of its longest side, and taking the mean color of
+// each box.
+type MedianCut struct{}
+
+// Octree quantizes colors by counting them in a tree that divides the color
+// cube in eight at each level, then merging the least common leaves into
+// their parents until few enough remain.
+type Octree struct{}
+
+var (
+	_ draw.Quantizer = MedianCut{}
+	_ draw.Quantizer = Octree{}
+)
+
+// Quantize implements draw.Quantizer.
+func (q MedianCut) Quantize(p color.Palette, m image.Image) color.Palette {
+	return quantize(q, p, m)
+}
+
+// Quantize implements draw.Quantizer.
+func (q Octree) Quantize(p color.Palette, m image.Image) color.Palette {
+	return quantize(q, p, m)
+}
+
+func quantize(q Quantizer, p color.Palette, m image.Image) color.Palette {
+	h := Histogram{}
+	h.Add(m)
+	return append(p, q.Palette(h, cap(p)-len(p))...)
+}
+
+// sorted returns the colors of a histogram in order, so that palettes do not
+// depend on the order of the map.
+func (h Histogram) sorted() box {
+	all := make(box, 0, len(h))
+	for c, k := range h {
+		all = append(all, weighted{c, k})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		a, b := all[i].c, all[j].c
+		return a.R < b.R || a.R == b.R && (a.G < b.G || a.G == b.G && (a.B < b.B || a.B == b.B && a.A < b.A))
+	})
+	return all
+}
+
+type weighted struct {
+	c color.RGBA
+	n int
+}
+
+type box []weighted
+
+func (b box) channel(c color.RGBA, k int) uint8 {
+	switch k {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	case 2:
+		return c.B
+	}
+	return c.A
+}
+
+// widest returns the channel along which the box is longest, and its length.
+func (b box) widest() (int, int) {
+	lo := [4]uint8{0xff, 0xff, 0xff, 0xff}
+	var hi [4]uint8
+	for _, w := range b {
+		for k := 0; k < 4; k++ {
+			v := b.channel(w.c, k)
+			if v < lo[k] {
+				lo[k] = v
+			}
+			if v > hi[k] {
+				hi[k] = v
+			}
+		}
+	}
+	channel, length := 0, -1
+	for k := 0; k < 4; k++ {
+		if int(hi[k])-int(lo[k]) > length {
+			channel, length = k, int(hi[k])-int(lo[k])
+		}
+	}
+	return channel, length
+}
+
+func (b box) count() int {
+	n := 0
+	for _, w := range b {
+		n += w.n
+	}
+	return n
+}
+
+func (b box) mean() color.Color {
+	var r, g, bl, a, n int
+	for _, w := range b {
+		r += int(w.c.R) * w.n
+		g += int(w.c.G) * w.n
+		bl += int(w.c.B) * w.n
+		a += int(w.c.A) * w.n
+		n += w.n
+	}
+	return color.RGBA{uint8((r + n/2) / n), uint8((g + n/2) / n), uint8((bl + n/2) / n), uint8((a + n/2) / n)}
+}
+
+// Palette implements Quantizer.
+func (MedianCut) Palette(h Histogram, n int) color.Palette {
+	if n <= 0 || len(h) == 0 {
+		return nil
+	}
+	boxes := []box{h.sorted()}
+	for len(boxes) < n {
+		// Divide the box with the most pixels over the longest side.
+		best, score, channel := -1, 0, 0
+		for i, b := range boxes {
+			if len(b) < 2 {
+				continue
+			}
+			k, length := b.widest()
+			if s := length * b.count(); s > score || best < 0 {
+				best, score, channel = i, s, k
+			}
+		}
+		if best < 0 {
+			break
+		}
+		b := boxes[best]
+		sort.SliceStable(b, func(i, j int) bool {
+			return b.channel(b[i].c, channel) < b.channel(b[j].c, channel)
+		})
+		half, seen, cut := b.count()/2, 0, 1
+		for i, w := range b[:len(b)-1] {
+			seen += w.n
+			cut = i + 1
+			if seen >= half {
+				break
+			}
+		}
+		boxes[best] = b[:cut:cut]
+		boxes = append(boxes, b[cut:])
+	}
+
+	pal := make(color.Palette, len(boxes))
+	for i, b := range boxes {
+		pal[i] = b.mean()
+	}
+	return pal
+}
+
+type octant struct {
+	children       [8]*octant
+	r, g, b, a, n  int
+	leaf           bool
+	level, ordinal int
+}
+
+// Palette implements Quantizer.
+func (Octree) Palette(h Histogram, n int) color.Palette {
+	if n <= 0 || len(h) == 0 {
+		return nil
+	}
+	root := &octant{}
+	var levels [8][]*octant
+	leaves := 0
+	ordinal := 0
+	for _, w := range h.sorted() {
+		c, k := w.c, w.n
+		node := root
+		for level := 0; level < 8; level++ {
+			node.r += int(c.R) * k
+			node.g += int(c.G) * k
+			node.b += int(c.B) * k
+			node.a += int(c.A) * k
+			node.n += k
+			shift := 7 - level
+			i := int(c.R>>shift&1)<<2 | int(c.G>>shift&1)<<1 | int(c.B>>shift&1)
+			child := node.children[i]
+			if child == nil {
+				child = &octant{level: level + 1, ordinal: ordinal}
+				ordinal++
+				node.children[i] = child
+				if level < 7 {
+					levels[level+1] = append(levels[level+1], child)
+				} else {
+					child.leaf = true
+					leaves++
+				}
+			}
+			node = child
+		}
+		node.r += int(c.R) * k
+		node.g += int(c.G) * k
+		node.b += int(c.B) * k
+		node.a += int(c.A) * k
+		node.n += k
+	}
+	levels[0] = []*octant{root}
+
+	// Merge the least common branches of the deepest level first.
+	for level := 7; level >= 0 && leaves > n; level-- {
+		nodes := levels[level]
+		sort.Slice(nodes, func(i, j int) bool {
+			if nodes[i].n != nodes[j].n {
+				return nodes[i].n < nodes[j].n
+			}
+			return nodes[i].ordinal < nodes[j].ordinal
+		})
+		for _, node := range nodes {
+			if leaves <= n {
+				break
+			}
+			children := 0
+			for i, child := range node.children {
+				if child != nil {
+					children++
+					node.children[i] = nil
+				}
+			}
+			node.leaf = true
+			leaves -= children - 1
+		}
+	}
+
+	var pal color.Palette
+	var visit func(o *octant)
+	visit = func(o *octant) {
+		if o.leaf {
+			pal = append(pal, color.RGBA{
+				uint8((o.r + o.n/2) / o.n),
+				uint8((o.g + o.n/2) / o.n),
+				uint8((o.b + o.n/2) / o.n),
+				uint8((o.a + o.n/2) / o.n),
+			})
+			return
+		}
+		for _, child := range o.children {
+			if child != nil {
+				visit(child)
+			}
+		}
+	}
+	visit(root)
+	return pal
+}
+
+// Palettizer converts true-color frames into paletted frames.
+// With a Palette, every frame shares it.
+// Otherwise, each frame gets a palette of its own from the Quantizer, or
+// frames may share a palette learned from all of them by observing each
+// frame in a first pass and then fixing the palette.
+type Palettizer struct {
+	Quantizer Quantizer
+	// Colors is the most colors in a palette, 256 if zero.
+	Colors int
+	// Dither diffuses the error of each pixel into its neighbors.
+	Dither  bool
+	Palette color.Palette
+
+	histogram Histogram
+}
+
+func (p *Palettizer) colors() int {
+	if p.Colors == 0 {
+		return 256
+	}
+	return p.Colors
+}
+
+// Observe adds the colors of a frame to those that Fix chooses a palette
+// for.
+func (p *Palettizer) Observe(img image.Image) {
+	if p.histogram == nil {
+		p.histogram = Histogram{}
+	}
+	p.histogram.Add(img)
+}
+
+// Fix chooses the palette for every frame from the frames observed so far.
+func (p *Palettizer) Fix() {
+	p.Palette = p.Quantizer.Palette(p.histogram, p.colors())
+	p.histogram = nil
+}
+
+// Paletted converts a frame.
+func (p *Palettizer) Paletted(img image.Image) *image.Paletted {
+	if pi, ok := img.(*image.Paletted); ok && p.Palette == nil {
+		return pi
+	}
+	pal := p.Palette
+	if pal == nil {
+		h := Histogram{}
+		h.Add(img)
+		pal = p.Quantizer.Palette(h, p.colors())
+	}
+	return Paletted(img, pal, p.Dither)
+}
+
+// Paletted converts an image to a palette, by the nearest color to each pixel,
+// or with dithering.
+func Paletted(img image.Image, pal color.Palette, dither bool) *image.Paletted {
+	b := img.Bounds()
+	out := image.NewPaletted(b, pal)
+	if dither {
+		draw.FloydSteinberg.Draw(out, b, img, b.Min)
+	} else {
+		draw.Draw(out, b, img, b.Min, draw.Src)
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package viz
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuantizersKeepFewColors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	colors := []color.RGBA{
+		{0xff, 0, 0, 0xff},
+		{0, 0xff, 0, 0xff},
+		{0, 0, 0xff, 0xff},
+		{0x10, 0x20, 0x30, 0xff},
+	}
+	for i := range img.Pix[:len(img.Pix)/4] {
+		c := colors[i%len(colors)]
+		img.SetRGBA(i%16, i/16, c)
+	}
+	for _, q := range []Quantizer{MedianCut{}, Octree{}} {
+		p := &Palettizer{Quantizer: q, Colors: 8}
+		out := p.Paletted(img)
+		assert.Len(t, out.Palette, len(colors))
+		same := true
+		for y := 0; y < 16; y++ {
+			for x := 0; x < 16; x++ {
+				same = same && out.At(x, y) == img.At(x, y)
+			}
+		}
+		assert.True(t, same)
+	}
+}
+
+func TestQuantizersLimitColors(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.SetRGBA(x, y, color.RGBA{uint8(x * 4), uint8(y * 4), uint8(x ^ y), 0xff})
+		}
+	}
+	h := Histogram{}
+	h.Add(img)
+	for _, q := range []Quantizer{MedianCut{}, Octree{}} {
+		pal := q.Palette(h, 16)
+		assert.True(t, len(pal) <= 16)
+		assert.True(t, len(pal) >= 8)
+	}
+}
@@ -0,0 +1,237 @@
+package viz
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/kriskowal/bottle-world/viz/colormap"
+)
+
+// D8 returns the direction that water flows out of a cell in the D8
+// encoding of GIS tools, with east 1, then clockwise 2, 4, 8 and so on to
+// northeast 128, or 0 where water does not flow.
+// Water in the simulation flows only north, south, west or east.
+func D8(c *sim.Cell) int {
+	switch c.WaterShed {
+	case 1:
+		return 64
+	case 2:
+		return 4
+	case 3:
+		return 16
+	case 4:
+		return 1
+	}
+	return 0
+}
+
+func init() {
+	RegisterLayer(&Layer{
+		Name:        "flow-direction",
+		Description: "direction of the water flowing out of each cell, in D8 codes",
+		Value:       func(c *sim.Cell) float64 { return float64(D8(c)) },
+		ColorMap:    colormap.Viridis,
+		Categories:  directions(64, 4, 16, 1, 0),
+		Set:         colormap.OkabeIto,
+	})
+}
+
+// RasterLayers are the layers that GIS tools most often want.
+var RasterLayers = []string{"elevation", "water", "water-elevation", "heat", "flow-direction"}
+
+// RasterFormats are the extensions of the files that rasters write:
+// ESRI ASCII Grid and GeoTIFF.
+var RasterFormats = []string{".asc", ".tif", ".tiff"}
+
+// Raster is a layer of a world as a grid of values for GIS tools, with the
+// north row first, the cell at the top left of the field at the top left of
+// the grid, and cells one unit across on a local plane.
+type Raster struct {
+	Width, Height int
+	// Values are the values of the cells row by row.
+	Values []float64
+	// Integer is whether every value is a whole number, so written as
+	// integers.
+	Integer bool
+}
+
+// NewRaster captures a layer of a world.
+func NewRaster(w *sim.World, l *Layer) *Raster {
+	r := &Raster{Width: w.Width, Height: w.Height, Values: make([]float64, 0, w.Width*w.Height), Integer: true}
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			v := l.Value(&w.Field[x][y])
+			if v != math.Trunc(v) || math.Abs(v) > math.MaxInt32 {
+				r.Integer = false
+			}
+			r.Values = append(r.Values, v)
+		}
+	}
+	return r
+}
+
+// Write writes a raster as ESRI ASCII Grid or GeoTIFF, by the extension of
+// a path.
+func (r *Raster) Write(out io.Writer, path string) error {
+	switch filepath.Ext(path) {
+	case ".asc":
+		return r.WriteASCIIGrid(out)
+	case ".tif", ".tiff":
+		return r.WriteGeoTIFF(out)
+	}
+	return fmt.Errorf("%s: unknown raster format, expected an extension of .asc, .tif or .tiff", path)
+}
+
+// WriteASCIIGrid writes a raster as an ESRI ASCII Grid, with its lower left
+// corner at the origin.
+func (r *Raster) WriteASCIIGrid(out io.Writer) error {
+	b := bufio.NewWriter(out)
+	fmt.Fprintf(b, "ncols %d\nnrows %d\nxllcorner 0\nyllcorner 0\ncellsize 1\n", r.Width, r.Height)
+	var number []byte
+	for y := 0; y < r.Height; y++ {
+		for x, v := range r.Values[y*r.Width : (y+1)*r.Width] {
+			if x > 0 {
+				b.WriteByte(' ')
+			}
+			number = strconv.AppendFloat(number[:0], v, 'f', -1, 64)
+			b.Write(number)
+		}
+		b.WriteByte('\n')
+	}
+	return b.Flush()
+}
+
+// Tags of TIFF and GeoTIFF.
+const (
+	tiffImageWidth      = 256
+	tiffImageLength     = 257
+	tiffBitsPerSample   = 258
+	tiffCompression     = 259
+	tiffPhotometric     = 262
+	tiffStripOffsets    = 273
+	tiffSamplesPerPixel = 277
+	tiffRowsPerStrip    = 278
+	tiffStripByteCounts = 279
+	tiffPlanarConfig    = 284
+	tiffSampleFormat    = 339
+	tiffModelPixelScale = 33550
+	tiffModelTiepoint   = 33922
+	tiffGeoKeyDirectory = 34735
+)
+
+// Types of the values of TIFF tags.
+const (
+	tiffShort  = 3
+	tiffLong   = 4
+	tiffDouble = 12
+)
+
+// tiffSizes are the bytes of each type of value.
+var tiffSizes = map[uint16]int{tiffShort: 2, tiffLong: 4, tiffDouble: 8}
+
+// WriteGeoTIFF writes a raster as a little-endian GeoTIFF of a single
+// uncompressed strip of 32-bit samples, integers if the raster has only
+// whole numbers and floating point otherwise, in a projected coordinate
+// system of its own, with the top left corner of the grid at y of the
+// height of the grid.
+func (r *Raster) WriteGeoTIFF(out io.Writer) error {
+	sampleFormat := uint16(3)
+	if r.Integer {
+		sampleFormat = 2
+	}
+	pixels := make([]byte, 4*len(r.Values))
+	for i, v := range r.Values {
+		bits := math.Float32bits(float32(v))
+		if r.Integer {
+			bits = uint32(int32(v))
+		}
+		binary.LittleEndian.PutUint32(pixels[4*i:], bits)
+	}
+
+	type entry struct {
+		tag, kind uint16
+		// values are the shorts, longs or doubles of the tag.
+		values interface{}
+	}
+	entries := []entry{
+		{tiffImageWidth, tiffLong, []uint32{uint32(r.Width)}},
+		{tiffImageLength, tiffLong, []uint32{uint32(r.Height)}},
+		{tiffBitsPerSample, tiffShort, []uint16{32}},
+		{tiffCompression, tiffShort, []uint16{1}},
+		// Black is zero.
+		{tiffPhotometric, tiffShort, []uint16{1}},
+		{tiffStripOffsets, tiffLong, []uint32{0}},
+		{tiffSamplesPerPixel, tiffShort, []uint16{1}},
+		{tiffRowsPerStrip, tiffLong, []uint32{uint32(r.Height)}},
+		{tiffStripByteCounts, tiffLong, []uint32{uint32(len(pixels))}},
+		{tiffPlanarConfig, tiffShort, []uint16{1}},
+		{tiffSampleFormat, tiffShort, []uint16{sampleFormat}},
+		{tiffModelPixelScale, tiffDouble, []float64{1, 1, 0}},
+		// The raster point at the top left corner of the grid is the model
+		// point at the top left corner of the plane.
+		{tiffModelTiepoint, tiffDouble, []float64{0, 0, 0, 0, float64(r.Height), 0}},
+		{tiffGeoKeyDirectory, tiffShort, []uint16{
+			1, 1, 0, 3,
+			// A projected model,
+			1024, 0, 1, 1,
+			// whose pixels are areas,
+			1025, 0, 1, 1,
+			// in a coordinate system of its own.
+			3072, 0, 1, 32767,
+		}},
+	}
+
+	// The header, then the directory, then the values that do not fit in
+	// the directory, then the pixels.
+	const header = 8
+	directory := 2 + 12*len(entries) + 4
+	var extra bytes.Buffer
+	offset := func() uint32 { return uint32(header + directory + extra.Len()) }
+	var ifd bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&ifd, le, uint16(len(entries)))
+	for i := range entries {
+		e := &entries[i]
+		var data bytes.Buffer
+		binary.Write(&data, le, e.values)
+		count := data.Len() / tiffSizes[e.kind]
+		binary.Write(&ifd, le, e.tag)
+		binary.Write(&ifd, le, e.kind)
+		binary.Write(&ifd, le, uint32(count))
+		if data.Len() <= 4 {
+			for data.Len() < 4 {
+				data.WriteByte(0)
+			}
+			ifd.Write(data.Bytes())
+			continue
+		}
+		binary.Write(&ifd, le, offset())
+		extra.Write(data.Bytes())
+	}
+	binary.Write(&ifd, le, uint32(0))
+
+	// The pixels come after everything else, so their offset is known only
+	// now.
+	stripOffset := offset()
+	b := ifd.Bytes()
+	for i, e := range entries {
+		if e.tag == tiffStripOffsets {
+			le.PutUint32(b[2+12*i+8:], stripOffset)
+		}
+	}
+
+	w := bufio.NewWriter(out)
+	w.Write([]byte{'I', 'I', 42, 0})
+	binary.Write(w, le, uint32(header))
+	w.Write(b)
+	w.Write(extra.Bytes())
+	w.Write(pixels)
+	return w.Flush()
+}
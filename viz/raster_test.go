@@ -0,0 +1,45 @@
+package viz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRaster(t *testing.T) {
+	w := &sim.World{Width: 3, Height: 2, Field: sim.NewField(3, 2)}
+	w.Field[1][0].WaterShed = 4
+	w.Field[2][1].WaterShed = 1
+	w.Field[0][1].SurfaceHeat = 5
+
+	r := NewRaster(w, Layers["flow-direction"])
+	assert.Equal(t, []float64{0, 1, 0, 0, 0, 64}, r.Values)
+	assert.True(t, r.Integer)
+
+	var out bytes.Buffer
+	assert.NoError(t, NewRaster(w, Layers["heat"]).WriteASCIIGrid(&out))
+	assert.Equal(t, "ncols 3\nnrows 2\nxllcorner 0\nyllcorner 0\ncellsize 1\n0 0 0\n5 0 0\n", out.String())
+
+	out.Reset()
+	r = &Raster{Width: 2, Height: 1, Values: []float64{0.5, -2}}
+	assert.NoError(t, r.WriteGeoTIFF(&out))
+	b := out.Bytes()
+	le := binary.LittleEndian
+	assert.Equal(t, "II", string(b[:2]))
+	assert.Equal(t, uint16(42), le.Uint16(b[2:]))
+	ifd := b[le.Uint32(b[4:]):]
+	tags := map[uint16]uint32{}
+	for i := 0; i < int(le.Uint16(ifd)); i++ {
+		e := ifd[2+12*i:]
+		tags[le.Uint16(e)] = le.Uint32(e[8:])
+	}
+	assert.Equal(t, uint32(2), tags[tiffImageWidth])
+	assert.Equal(t, uint32(3), tags[tiffSampleFormat])
+	strip := b[tags[tiffStripOffsets]:]
+	assert.Equal(t, 8, len(strip))
+	assert.Equal(t, float32(-2), math.Float32frombits(le.Uint32(strip[4:])))
+}
@@ -0,0 +1,155 @@
+package viz
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/kriskowal/bottle-world/sim"
+)
+
+// Hillshade lights the terrain from a distant source, so that its shape
+// shows through the colors of other layers.
+// Every field means what it says, even zero, so most hillshades start from
+// DefaultHillshade.
+type Hillshade struct {
+	// Azimuth is the direction of the light in degrees clockwise from
+	// north.
+	Azimuth float64
+	// Altitude is the angle of the light above the horizon in degrees.
+	Altitude float64
+	// Exaggeration scales the heights of the terrain, where 1 raises the
+	// whole range of elevation over an eighth of the width of the world.
+	Exaggeration float64
+	// Strength is how much the shade darkens the colors beneath it, from 0
+	// to 1.
+	Strength float64
+}
+
+// DefaultHillshade lights the terrain from the northwest, halfway up the
+// sky.
+var DefaultHillshade = Hillshade{Azimuth: 315, Altitude: 45, Exaggeration: 1, Strength: 1}
+
+// Shade returns the light falling on each cell of a world, from 0 in shadow
+// to 1 facing the light, indexed like the field.
+func (h Hillshade) Shade(w *sim.World) [][]float64 {
+	width, height := w.Width, w.Height
+	breadth := float64(w.HighestSurfaceElevation - w.LowestSurfaceElevation)
+	if breadth == 0 {
+		breadth = 1
+	}
+	scale := h.Exaggeration * float64(width) / 8 / breadth
+
+	azimuth := h.Azimuth * math.Pi / 180
+	altitude := h.Altitude * math.Pi / 180
+	// Toward the light, with x east, y south and z up.
+	lx := math.Sin(azimuth) * math.Cos(altitude)
+	ly := -math.Cos(azimuth) * math.Cos(altitude)
+	lz := math.Sin(altitude)
+
+	elevation := func(x, y int) float64 {
+		return float64(w.Field[(x+width)%width][(y+height)%height].SurfaceElevation) * scale
+	}
+	shade := make([][]float64, width)
+	for x := range shade {
+		shade[x] = make([]float64, height)
+		for y := range shade[x] {
+			dx := (elevation(x+1, y) - elevation(x-1, y)) / 2
+			dy := (elevation(x, y+1) - elevation(x, y-1)) / 2
+			// The normal of the surface is (-dx, -dy, 1).
+			s := (-dx*lx - dy*ly + lz) / math.Sqrt(dx*dx+dy*dy+1)
+			shade[x][y] = math.Max(0, s)
+		}
+	}
+	return shade
+}
+
+// Contours draws lines where a layer crosses multiples of an interval.
+type Contours struct {
+	// Layer is the name of the layer, elevation if empty.
+	Layer    string
+	Interval float64
+	// Color is the color of the lines, black if nil.
+	Color color.Color
+	// Opacity is how much the lines cover the colors beneath them, from 0
+	// to 1, 1 if zero.
+	Opacity float64
+}
+
+// Lines reports which cells of a world a contour line passes through,
+// indexed like the field.
+// A line passes through a cell if the layer crosses a multiple of the
+// interval between the cell and its neighbor to the east or south.
+func (c Contours) Lines(w *sim.World) [][]bool {
+	name := c.Layer
+	if name == "" {
+		name = "elevation"
+	}
+	layer := Layers[name]
+	width, height := w.Width, w.Height
+	level := func(x, y int) float64 {
+		return math.Floor(layer.Value(&w.Field[x%width][y%height]) / c.Interval)
+	}
+	lines := make([][]bool, width)
+	for x := range lines {
+		lines[x] = make([]bool, height)
+		for y := range lines[x] {
+			l := level(x, y)
+			lines[x][y] = l != level(x+1, y) || l != level(x, y+1)
+		}
+	}
+	return lines
+}
+
+// Relief blends hillshading and contour lines over the colors of another
+// rendering.
+type Relief struct {
+	Hillshade *Hillshade
+	Contours  *Contours
+}
+
+// Render returns a function that colors the cell at each position of a
+// world like another function, then shades it and draws contour lines over
+// it.
+func (r Relief) Render(w *sim.World, getColor func(c *sim.Cell) color.Color) func(x, y int) color.Color {
+	var shade [][]float64
+	var strength float64
+	if r.Hillshade != nil {
+		shade = r.Hillshade.Shade(w)
+		strength = r.Hillshade.Strength
+	}
+	var lines [][]bool
+	var ink color.RGBA
+	opacity := 1.0
+	if r.Contours != nil {
+		lines = r.Contours.Lines(w)
+		ink = color.RGBA{0, 0, 0, 0xff}
+		if r.Contours.Color != nil {
+			ink = color.RGBAModel.Convert(r.Contours.Color).(color.RGBA)
+		}
+		if r.Contours.Opacity != 0 {
+			opacity = r.Contours.Opacity
+		}
+	}
+	return func(x, y int) color.Color {
+		out := color.RGBAModel.Convert(getColor(&w.Field[x][y])).(color.RGBA)
+		if shade != nil {
+			f := 1 - strength*(1-shade[x][y])
+			out.R = uint8(float64(out.R) * f)
+			out.G = uint8(float64(out.G) * f)
+			out.B = uint8(float64(out.B) * f)
+		}
+		if lines != nil && lines[x][y] {
+			out = lerp(out, ink, opacity)
+		}
+		return out
+	}
+}
+
+// Render colors the cell at each position of a world by its hillshade
+// alone, in gray.
+func (h Hillshade) Render(w *sim.World) func(x, y int) color.Color {
+	shade := h.Shade(w)
+	return func(x, y int) color.Color {
+		return Gray(uint8(shade[x][y] * 0xff))
+	}
+}
@@ -0,0 +1,46 @@
+package viz
+
+import (
+	"testing"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/stretchr/testify/assert"
+)
+
+// ramp returns a world that rises to the east by ten each cell, wrapping to
+// a cliff at the edge of the torus.
+func ramp(width, height int) *sim.World {
+	w := &sim.World{Width: width, Height: height, Field: sim.NewField(width, height)}
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			w.Field[x][y].SurfaceElevation = 10 * x
+		}
+	}
+	w.HighestSurfaceElevation = 10 * (width - 1)
+	return w
+}
+
+func TestHillshadeFacesTheLight(t *testing.T) {
+	w := ramp(10, 4)
+	west, east, north := DefaultHillshade, DefaultHillshade, DefaultHillshade
+	west.Azimuth, east.Azimuth, north.Azimuth = 270, 90, 0
+	// The slope faces west, so light from the west falls on it more than
+	// light from across it, and light from the north only grazes it.
+	lit, dark, grazed := west.Shade(w)[4][1], east.Shade(w)[4][1], north.Shade(w)[4][1]
+	assert.True(t, lit > grazed)
+	assert.True(t, grazed > dark)
+}
+
+func TestContours(t *testing.T) {
+	w := ramp(10, 2)
+	lines := Contours{Interval: 25}.Lines(w)
+	var crossed []int
+	for x := 0; x < w.Width; x++ {
+		assert.Equal(t, lines[x][0], lines[x][1])
+		if lines[x][0] {
+			crossed = append(crossed, x)
+		}
+	}
+	// Between 20 and 30, 40 and 50, 70 and 80, and over the cliff.
+	assert.Equal(t, []int{2, 4, 7, 9}, crossed)
+}
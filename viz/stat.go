@@ -0,0 +1,85 @@
+package viz
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/kriskowal/bottle-world/sim"
+)
+
+// Stat is a quantity of a whole world, like its hottest surface.
+type Stat struct {
+	Name        string
+	Description string
+	Value       func(w *sim.World) float64
+}
+
+// Stats are the statistics of a world that can be shown, by name.
+// Every numeric field of a world but its dimensions has a statistic, named
+// for the field in lower case with words separated by hyphens, like
+// "hottest-surface", in addition to the statistics registered with other
+// names.
+var Stats = map[string]*Stat{}
+
+// RegisterStat adds a statistic, replacing any of the same name.
+func RegisterStat(s *Stat) {
+	Stats[s.Name] = s
+}
+
+// StatNames returns the names of the statistics in order.
+func StatNames() []string {
+	names := make([]string, 0, len(Stats))
+	for name := range Stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// total returns the value of a statistic that sums a quantity of every cell.
+func total(quantity func(c *sim.Cell) int) func(w *sim.World) float64 {
+	return func(w *sim.World) float64 {
+		total := 0
+		for x := 0; x < w.Width; x++ {
+			for y := 0; y < w.Height; y++ {
+				total += quantity(&w.Field[x][y])
+			}
+		}
+		return float64(total)
+	}
+}
+
+func init() {
+	RegisterStat(&Stat{
+		Name:        "total-water",
+		Description: "water over the terrain of every cell",
+		Value:       total(func(c *sim.Cell) int { return c.Water }),
+	})
+	RegisterStat(&Stat{
+		Name:        "total-soil-moisture",
+		Description: "water held in the soil of every cell",
+		Value:       total(func(c *sim.Cell) int { return c.SoilMoisture }),
+	})
+	RegisterStat(&Stat{
+		Name:        "total-ground-water",
+		Description: "water held in the aquifer under every cell",
+		Value:       total(func(c *sim.Cell) int { return c.GroundWater }),
+	})
+
+	fields := reflect.TypeOf(sim.World{})
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		// The dimensions of a world never change.
+		if field.Type.Kind() != reflect.Int || field.Name == "Width" || field.Name == "Height" {
+			continue
+		}
+		index := i
+		RegisterStat(&Stat{
+			Name:        hyphenate(field.Name),
+			Description: "the " + field.Name + " of the world",
+			Value: func(w *sim.World) float64 {
+				return float64(reflect.ValueOf(w).Elem().Field(index).Int())
+			},
+		})
+	}
+}
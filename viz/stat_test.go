@@ -0,0 +1,16 @@
+package viz
+
+import (
+	"testing"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	w := &sim.World{Width: 10, Height: 4, HottestSurface: 7}
+	assert.Equal(t, 7.0, Stats["hottest-surface"].Value(w))
+	// The dimensions of a world are not statistics of it.
+	assert.True(t, Stats["width"] == nil)
+	assert.True(t, Stats["height"] == nil)
+}
@@ -0,0 +1,58 @@
+package viz
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Trails draws the recent trajectories of the tracers of a scene, fading
+// toward their tails, with a dot at the head of each.
+type Trails struct {
+	// Length is the number of positions of each trajectory to draw, 20 if
+	// zero.
+	Length int
+	// Color is the color of the trails, white if nil.
+	Color color.Color
+}
+
+func (tr Trails) Draw(dst draw.Image, s Scene) {
+	if s.Tracers == nil {
+		return
+	}
+	length := tr.Length
+	if length <= 0 {
+		length = 20
+	}
+	c := tr.Color
+	if c == nil {
+		c = ink
+	}
+	r, g, b, _ := c.RGBA()
+	fade := func(alpha float64) color.Color {
+		return color.RGBA64{
+			uint16(float64(r) * alpha),
+			uint16(float64(g) * alpha),
+			uint16(float64(b) * alpha),
+			uint16(0xffff * alpha),
+		}
+	}
+	zoom := s.View.zoom()
+	for _, t := range s.Tracers.Tracers {
+		path := t.Trajectory
+		if len(path) > length {
+			path = path[len(path)-length:]
+		}
+		head := path[len(path)-1]
+		// Draw the whole trail wherever its head shows.
+		for _, p := range s.View.places(s.World, head[0], head[1]) {
+			dx, dy := p[0]-head[0], p[1]-head[1]
+			for i := 1; i < len(path); i++ {
+				a, b := path[i-1], path[i]
+				line(dst, (a[0]+dx)*zoom, (a[1]+dy)*zoom, (b[0]+dx)*zoom, (b[1]+dy)*zoom, fade(float64(i)/float64(len(path))))
+			}
+			at := image.Pt(int(p[0]*zoom), int(p[1]*zoom))
+			draw.Draw(dst, image.Rect(at.X-1, at.Y-1, at.X+2, at.Y+2), image.NewUniform(c), image.Point{}, draw.Over)
+		}
+	}
+}
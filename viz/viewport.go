@@ -0,0 +1,144 @@
+package viz
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/kriskowal/bottle-world/sim"
+)
+
+// Viewport chooses the part of the torus that a capture shows, and at what
+// size.
+// Positions are in cells, and wrap around the torus, so a view may show a
+// cell more than once.
+// The zero viewport shows the whole world from its corner and a quarter
+// again of its width and height, with one pixel for each cell.
+type Viewport struct {
+	// Origin is the cell at the top left of the view.
+	Origin image.Point
+	// Zoom is the number of pixels across each cell, 1 if zero.
+	Zoom float64
+	// Bilinear blends the colors of neighboring cells between their
+	// centers, instead of showing each cell as a block of its own color.
+	Bilinear bool
+	// Tiles is the number of times the view repeats the torus across and
+	// down.
+	// If zero, the view shows a quarter again of the torus.
+	Tiles int
+	// Crop, if not empty, is the rectangle of cells to show, from the
+	// origin, instead of the tiles.
+	Crop image.Rectangle
+}
+
+func (v Viewport) zoom() float64 {
+	if v.Zoom == 0 {
+		return 1
+	}
+	return v.Zoom
+}
+
+// Cells returns the rectangle of cells that the view shows, from the origin.
+func (v Viewport) Cells(w *sim.World) image.Rectangle {
+	if !v.Crop.Empty() {
+		return v.Crop
+	}
+	if v.Tiles > 0 {
+		return image.Rect(0, 0, w.Width*v.Tiles, w.Height*v.Tiles)
+	}
+	return image.Rect(0, 0, w.Width*5/4, w.Height*5/4)
+}
+
+// Bounds returns the bounds of the images that the view captures.
+func (v Viewport) Bounds(w *sim.World) image.Rectangle {
+	size := v.Cells(w).Size()
+	zoom := v.zoom()
+	return image.Rect(0, 0, int(math.Round(float64(size.X)*zoom)), int(math.Round(float64(size.Y)*zoom)))
+}
+
+// Capture renders a world through the view in true color.
+func (v Viewport) Capture(w *sim.World, getColor func(c *sim.Cell) color.Color) *image.RGBA {
+	return v.CaptureCells(w, func(x, y int) color.Color {
+		return getColor(&w.Field[x][y])
+	})
+}
+
+// CaptureCells is like Capture, but colors each cell by its position in
+// the field, for renderings that look at the neighbors of a cell.
+func (v Viewport) CaptureCells(w *sim.World, getColor func(x, y int) color.Color) *image.RGBA {
+	colors := make([]color.RGBA, w.Width*w.Height)
+	for x := 0; x < w.Width; x++ {
+		for y := 0; y < w.Height; y++ {
+			colors[x*w.Height+y] = color.RGBAModel.Convert(getColor(x, y)).(color.RGBA)
+		}
+	}
+	at := func(x, y int) color.RGBA {
+		x = ((x+v.Origin.X)%w.Width + w.Width) % w.Width
+		y = ((y+v.Origin.Y)%w.Height + w.Height) % w.Height
+		return colors[x*w.Height+y]
+	}
+
+	cells := v.Cells(w)
+	bounds := v.Bounds(w)
+	zoom := v.zoom()
+	img := image.NewRGBA(bounds)
+	for py := 0; py < bounds.Dy(); py++ {
+		for px := 0; px < bounds.Dx(); px++ {
+			// The center of the pixel in cells.
+			cx := (float64(px)+0.5)/zoom + float64(cells.Min.X)
+			cy := (float64(py)+0.5)/zoom + float64(cells.Min.Y)
+			if !v.Bilinear {
+				img.SetRGBA(px, py, at(int(math.Floor(cx)), int(math.Floor(cy))))
+				continue
+			}
+			fx, fy := cx-0.5, cy-0.5
+			x0, y0 := int(math.Floor(fx)), int(math.Floor(fy))
+			tx, ty := fx-float64(x0), fy-float64(y0)
+			img.SetRGBA(px, py, lerp(
+				lerp(at(x0, y0), at(x0+1, y0), tx),
+				lerp(at(x0, y0+1), at(x0+1, y0+1), tx),
+				ty,
+			))
+		}
+	}
+	return img
+}
+
+func lerp(a, b color.RGBA, t float64) color.RGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), mix(a.A, b.A)}
+}
+
+// Pixels returns the center of each place that a cell shows in the images
+// that the view captures, which may be none, or many where the view wraps
+// around the torus.
+func (v Viewport) Pixels(w *sim.World, cell image.Point) []image.Point {
+	zoom := v.zoom()
+	var pixels []image.Point
+	for _, p := range v.places(w, float64(cell.X)+0.5, float64(cell.Y)+0.5) {
+		pixels = append(pixels, image.Pt(int(p[0]*zoom), int(p[1]*zoom)))
+	}
+	return pixels
+}
+
+// places returns each place that a position in cells shows in the view, in
+// cells from its top left.
+func (v Viewport) places(w *sim.World, x, y float64) [][2]float64 {
+	cells := v.Cells(w)
+	width, height := float64(w.Width), float64(w.Height)
+	// The offsets from the origin of the position in the first tile.
+	x0 := math.Mod(math.Mod(x-float64(v.Origin.X), width)+width, width)
+	y0 := math.Mod(math.Mod(y-float64(v.Origin.Y), height)+height, height)
+	// The first offset of the position in the view.
+	x0 += math.Ceil((float64(cells.Min.X)-x0)/width) * width
+	y0 += math.Ceil((float64(cells.Min.Y)-y0)/height) * height
+	var places [][2]float64
+	for y := y0; y < float64(cells.Max.Y); y += height {
+		for x := x0; x < float64(cells.Max.X); x += width {
+			places = append(places, [2]float64{x - float64(cells.Min.X), y - float64(cells.Min.Y)})
+		}
+	}
+	return places
+}
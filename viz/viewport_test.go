@@ -0,0 +1,69 @@
+package viz
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/kriskowal/bottle-world/sim"
+	"github.com/stretchr/testify/assert"
+)
+
+// numbered returns a world whose cells are colored by their position,
+// with x in the red and y in the green.
+func numbered(width, height int) (*sim.World, func(c *sim.Cell) color.Color) {
+	w := &sim.World{Width: width, Height: height, Field: sim.NewField(width, height)}
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			w.Field[x][y].SurfaceElevation = x<<8 | y
+		}
+	}
+	return w, func(c *sim.Cell) color.Color {
+		return color.RGBA{uint8(c.SurfaceElevation >> 8), uint8(c.SurfaceElevation), 0, 0xff}
+	}
+}
+
+func cellAt(img *image.RGBA, x, y int) [2]int {
+	c := img.RGBAAt(x, y)
+	return [2]int{int(c.R), int(c.G)}
+}
+
+func TestViewportZoomsAndTiles(t *testing.T) {
+	w, getColor := numbered(4, 2)
+	img := Viewport{Zoom: 2, Tiles: 1}.Capture(w, getColor)
+	assert.Equal(t, image.Rect(0, 0, 8, 4), img.Bounds())
+	assert.Equal(t, [2]int{1, 0}, cellAt(img, 3, 1))
+	assert.Equal(t, [2]int{3, 1}, cellAt(img, 7, 3))
+
+	img = Viewport{Tiles: 3}.Capture(w, getColor)
+	assert.Equal(t, image.Rect(0, 0, 12, 6), img.Bounds())
+	assert.Equal(t, [2]int{1, 0}, cellAt(img, 5, 0))
+	assert.Equal(t, [2]int{1, 1}, cellAt(img, 9, 5))
+
+	// The default shows a quarter again.
+	assert.Equal(t, image.Rect(0, 0, 5, 2), Viewport{}.Bounds(w))
+}
+
+func TestViewportCropsAcrossTheSeam(t *testing.T) {
+	w, getColor := numbered(4, 2)
+	img := Viewport{Origin: image.Pt(3, 1), Crop: image.Rect(0, 0, 2, 2)}.Capture(w, getColor)
+	assert.Equal(t, image.Rect(0, 0, 2, 2), img.Bounds())
+	assert.Equal(t, [2]int{3, 1}, cellAt(img, 0, 0))
+	assert.Equal(t, [2]int{0, 1}, cellAt(img, 1, 0))
+	assert.Equal(t, [2]int{0, 0}, cellAt(img, 1, 1))
+}
+
+func TestViewportBilinear(t *testing.T) {
+	w := &sim.World{Width: 2, Height: 1, Field: sim.NewField(2, 1)}
+	w.Field[1][0].SurfaceElevation = 0xff
+	img := Viewport{Zoom: 4, Tiles: 1, Bilinear: true}.Capture(w, func(c *sim.Cell) color.Color {
+		return Gray(uint8(c.SurfaceElevation))
+	})
+	var row []uint8
+	for x := 0; x < 8; x++ {
+		row = append(row, img.RGBAAt(x, 0).R)
+	}
+	// Colors blend between the centers of the cells, and across the seam
+	// of the torus.
+	assert.Equal(t, []uint8{96, 32, 32, 96, 159, 223, 223, 159}, row)
+}
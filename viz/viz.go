@@ -3,8 +3,7 @@ package viz
 import (
 	"image"
 	"image/color"
-	"image/gif"
-	"os"
+	"io"
 
 	"github.com/kriskowal/bottle-world/sim"
 )
@@ -13,32 +12,41 @@ func Gray(b uint8) color.Color {
 	return color.RGBA{b, b, b, 0xff}
 }
 
+// NewGrayScale returns a palette of all 256 grays.
 func NewGrayScale() color.Palette {
-	pal := make(color.Palette, 0)
-	for n := 0; n < 0xff; n++ {
+	pal := make(color.Palette, 0, 0x100)
+	for n := 0; n < 0x100; n++ {
 		pal = append(pal, Gray(uint8(n)))
 	}
 	return pal
 }
 
-func Capture(w *sim.World, pal color.Palette, getColor func(c *sim.Cell) color.Color) *image.Paletted {
-	width := w.Width * 5 / 4
-	height := w.Height * 5 / 4
-	img := image.NewPaletted(image.Rect(0, 0, width, height), pal)
-	for x := 0; x < width; x++ {
-		for y := 0; y < height; y++ {
-			img.Set(x, y, getColor(&w.Field[x%w.Width][y%w.Height]))
-		}
-	}
-	return img
+// Sink receives the frames of an animation as they are captured.
+type Sink interface {
+	// WriteFrame adds a frame that shows for delay hundredths of a second.
+	WriteFrame(img image.Image, delay int) error
+	// Close ends the animation.
+	Close() error
+}
+
+var (
+	_ Sink = (*GIFWriter)(nil)
+	_ Sink = (*APNGWriter)(nil)
+	_ Sink = (*PNGSequence)(nil)
+	_ Sink = (*Y4MWriter)(nil)
+)
+
+// Capture renders a world in true color, wrapping around the torus to a
+// quarter again its width and height.
+func Capture(w *sim.World, getColor func(c *sim.Cell) color.Color) *image.RGBA {
+	return Viewport{}.Capture(w, getColor)
 }
 
-func Write(w *sim.World, file string, pal color.Palette, getColor func(c *sim.Cell) color.Color) {
-	img := Capture(w, pal, getColor)
-	f, _ := os.OpenFile(file, os.O_WRONLY|os.O_CREATE, 0600)
-	defer f.Close()
-	gif.EncodeAll(f, &gif.GIF{
-		Image: []*image.Paletted{img},
-		Delay: []int{0},
+// Write writes a still of a world to a GIF file, in the nearest colors of a
+// palette.
+func Write(w *sim.World, file string, pal color.Palette, getColor func(c *sim.Cell) color.Color) error {
+	img := Paletted(Capture(w, getColor), pal, false)
+	return WriteFile(file, 0644, func(out io.Writer) error {
+		return EncodeGIF(out, []*image.Paletted{img}, []int{0})
 	})
 }
@@ -0,0 +1,153 @@
+package viz
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// Y4MWriter writes frames as uncompressed YUV4MPEG2 video, which offline
+// encoders take without loss of color or timing.
+// Frames are repeated to hold them for their delays at the frame rate of the
+// video, and may be scaled up by a whole factor.
+// The first frame sets the size of the video, and every frame must have
+// the same size.
+// Video is an even number of pixels across and down, as encoders of 4:2:0
+// chroma expect, repeating the last column or row of an odd frame.
+type Y4MWriter struct {
+	// Rate is the number of frames per second, 25 if zero.
+	Rate int
+	// Scale is the number of pixels of video across each pixel of a frame,
+	// 1 if zero.
+	Scale int
+
+	w       *bufio.Writer
+	frame   image.Point // of the first frame
+	size    image.Point // of the video
+	elapsed int         // hundredths of a second
+	frames  int         // frames of video, counting repetitions
+	y, cb   []byte
+	cr      []byte
+	err     error
+}
+
+// NewY4MWriter returns a writer of video with the given frame rate and
+// scale.
+func NewY4MWriter(w io.Writer, rate, scale int) *Y4MWriter {
+	return &Y4MWriter{Rate: rate, Scale: scale, w: bufio.NewWriter(w)}
+}
+
+func (v *Y4MWriter) rate() int {
+	if v.Rate == 0 {
+		return 25
+	}
+	return v.Rate
+}
+
+func (v *Y4MWriter) scale() int {
+	if v.Scale == 0 {
+		return 1
+	}
+	return v.Scale
+}
+
+// WriteFrame adds a frame that shows for delay hundredths of a second.
+// Every frame shows for at least one frame of video.
+func (v *Y4MWriter) WriteFrame(img image.Image, delay int) error {
+	if v.err != nil {
+		return v.err
+	}
+	size := img.Bounds().Size()
+	if v.y == nil {
+		v.frame = size
+		v.size = size.Mul(v.scale())
+		v.size.X += v.size.X % 2
+		v.size.Y += v.size.Y % 2
+		v.header()
+	} else if size != v.frame {
+		v.err = fmt.Errorf("viz: y4m frame of %v does not match the size of the first frame %v", size, v.frame)
+		return v.err
+	}
+	v.convert(img)
+
+	v.elapsed += delay
+	repeat := (v.elapsed*v.rate()+50)/100 - v.frames
+	if repeat < 1 {
+		repeat = 1
+	}
+	for i := 0; i < repeat; i++ {
+		v.w.WriteString("FRAME\n")
+		v.w.Write(v.y)
+		v.w.Write(v.cb)
+		v.w.Write(v.cr)
+	}
+	v.frames += repeat
+	if err := v.w.Flush(); err != nil {
+		v.err = err
+	}
+	return v.err
+}
+
+// Close ends the video.
+// It does not close the underlying writer.
+func (v *Y4MWriter) Close() error {
+	if v.err == errClosed {
+		return nil
+	}
+	if v.err != nil {
+		return v.err
+	}
+	if v.frames == 0 {
+		v.err = errors.New("viz: y4m has no frames")
+		return v.err
+	}
+	v.err = errClosed
+	return nil
+}
+
+func (v *Y4MWriter) header() {
+	fmt.Fprintf(v.w, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C420jpeg XCOLORRANGE=FULL\n", v.size.X, v.size.Y, v.rate())
+	cw, ch := v.size.X/2, v.size.Y/2
+	v.y = make([]byte, v.size.X*v.size.Y)
+	v.cb = make([]byte, cw*ch)
+	v.cr = make([]byte, cw*ch)
+}
+
+// convert scales a frame into the planes of the video, with the chroma of
+// each block of two by two pixels averaged.
+func (v *Y4MWriter) convert(img image.Image) {
+	b := img.Bounds()
+	scale := v.scale()
+	width, height := v.size.X, v.size.Y
+	cw := width / 2
+	cbs := make([]int, len(v.cb))
+	crs := make([]int, len(v.cr))
+	counts := make([]int, len(v.cb))
+	for y := 0; y < height; y++ {
+		// Padding repeats the last row and column.
+		py := y / scale
+		if py >= b.Dy() {
+			py = b.Dy() - 1
+		}
+		for x := 0; x < width; x++ {
+			px := x / scale
+			if px >= b.Dx() {
+				px = b.Dx() - 1
+			}
+			c := color.RGBAModel.Convert(img.At(b.Min.X+px, b.Min.Y+py)).(color.RGBA)
+			yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			v.y[y*width+x] = yy
+			i := y/2*cw + x/2
+			cbs[i] += int(cb)
+			crs[i] += int(cr)
+			counts[i]++
+		}
+	}
+	for i, n := range counts {
+		v.cb[i] = uint8((cbs[i] + n/2) / n)
+		v.cr[i] = uint8((crs[i] + n/2) / n)
+	}
+}
@@ -0,0 +1,56 @@
+package viz
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestY4M(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.White)
+
+	var out bytes.Buffer
+	v := NewY4MWriter(&out, 0, 2)
+	// At 25 frames a second, a tenth of a second is two and a half frames
+	// of video, which round to three and then two to keep time.
+	assert.NoError(t, v.WriteFrame(img, 10))
+	assert.NoError(t, v.WriteFrame(img, 10))
+	assert.NoError(t, v.Close())
+	assert.Error(t, v.WriteFrame(image.NewRGBA(image.Rect(0, 0, 2, 2)), 10))
+
+	header := "YUV4MPEG2 W6 H4 F25:1 Ip A1:1 C420jpeg XCOLORRANGE=FULL\n"
+	assert.True(t, strings.HasPrefix(out.String(), header))
+	// Scaled by two, each frame has six by four luma and three by two of
+	// each chroma.
+	frame := len("FRAME\n") + 6*4 + 2*3*2
+	frames := out.Bytes()[len(header):]
+	assert.Equal(t, 5*frame, len(frames))
+	assert.Equal(t, "FRAME\n", string(frames[:6]))
+	luma := frames[6 : 6+6*4]
+	// The white pixel covers two by two pixels of video.
+	assert.Equal(t, []byte{0xff, 0xff, 0, 0, 0, 0}, luma[:6])
+	assert.Equal(t, []byte{0xff, 0xff, 0, 0, 0, 0}, luma[6:12])
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0}, luma[12:18])
+}
+
+func TestY4MEvenSize(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 3))
+	img.Set(2, 2, color.White)
+	var out bytes.Buffer
+	v := NewY4MWriter(&out, 10, 0)
+	assert.NoError(t, v.WriteFrame(img, 0))
+	assert.NoError(t, v.Close())
+	// Video pads odd frames to even sizes by repeating their last row and
+	// column, and shows every frame at least once.
+	header := "YUV4MPEG2 W4 H4 F10:1 Ip A1:1 C420jpeg XCOLORRANGE=FULL\n"
+	assert.True(t, strings.HasPrefix(out.String(), header))
+	assert.Equal(t, len(header)+len("FRAME\n")+4*4+2*2*2, out.Len())
+	luma := out.Bytes()[len(header)+len("FRAME\n"):]
+	assert.Equal(t, []byte{0, 0, 0xff, 0xff}, luma[8:12])
+	assert.Equal(t, []byte{0, 0, 0xff, 0xff}, luma[12:16])
+}